@@ -1,2 +1,38 @@
+import os
+import threading
+import time
+
+OAUTH2_RULE = 'oauth2AuthHeader'
+OAUTH2_POLL_SECONDS = 5
+
 def zap_started(zap, target):
-	zap.script.load('authScript', 'authentication', 'Mozilla Zest', '/zap/wrk/authScript')
+	engine = os.environ.get('ZAP_AUTH_SCRIPT_ENGINE')
+	if engine:
+		zap.script.load('authScript', 'authentication', engine, '/zap/wrk/authScript')
+
+	# the ZAP runner refreshes the OAuth2 token in this file before it expires
+	token_file = os.environ.get('ZAP_OAUTH2_TOKEN_FILE')
+	if token_file:
+		modified = set_oauth2_header(zap, token_file, None)
+		threading.Thread(target=refresh_oauth2_header, args=(zap, token_file, modified), daemon=True).start()
+
+def set_oauth2_header(zap, token_file, modified):
+	current = os.stat(token_file).st_mtime_ns
+	if current == modified:
+		return modified
+
+	with open(token_file) as f:
+		value = f.read().strip()
+
+	zap.replacer.remove_rule(OAUTH2_RULE)
+	zap.replacer.add_rule(OAUTH2_RULE, 'true', 'REQ_HEADER', 'false', os.environ['ZAP_OAUTH2_HEADER'], value,
+		url=os.environ.get('ZAP_OAUTH2_HEADER_URL', ''))
+	return current
+
+def refresh_oauth2_header(zap, token_file, modified):
+	while True:
+		time.sleep(OAUTH2_POLL_SECONDS)
+		try:
+			modified = set_oauth2_header(zap, token_file, modified)
+		except Exception as e:
+			print('Unable to update OAuth2 authentication header: ' + str(e))
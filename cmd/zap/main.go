@@ -10,6 +10,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
@@ -40,6 +41,14 @@ const (
 	apiScanFailedExitCode                     = 20
 	copyReportFailedExitCode                  = 21
 	applyXsltFailedExitCode                   = 22
+	headerAuthenticationFailedExitCode        = 23
+	oauth2AuthenticationFailedExitCode        = 24
+	totpFailedExitCode                        = 25
+	userMetadataFailedExitCode                = 26
+	logoutLinksFailedExitCode                 = 27
+	outOfScopeTrafficExitCode                 = 28
+	activeScanTargetNotAllowedExitCode        = 29
+	setModeFailedExitCode                     = 30
 )
 
 func stopZap(quit chan int, wg *sync.WaitGroup) {
@@ -67,8 +76,38 @@ func exists(path string) (bool, error) {
 	return true, err
 }
 
+// overrideFlags holds the scan request file overrides from repeated -set flags.
+type overrideFlags []zap.ConfigOverride
+
+func (o *overrideFlags) String() string {
+	keys := make([]string, len(*o))
+	for i, override := range *o {
+		keys[i] = override.Key
+	}
+	return strings.Join(keys, ",")
+}
+
+func (o *overrideFlags) Set(value string) error {
+	override, err := zap.ParseConfigOverride(value)
+	if err != nil {
+		return err
+	}
+	*o = append(*o, override)
+	return nil
+}
+
 func main() {
 
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		migrate(os.Args[2:])
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "schema" {
+		schema(os.Args[2:])
+		return
+	}
+
 	const scanRequestFilePathFlagName = "scanRequestFile"
 	const zapApiScanPathFlagName = "zapApiScanPath"
 	const zapWorkDirFlagName = "zapWorkDir"
@@ -88,8 +127,21 @@ func main() {
 	zapApiScanPathFlag := flag.String(zapApiScanPathFlagName, "/zap/zap-api-scan.py", "a path to the ZAP API scan Python script")
 	zapWorkDirFlag := flag.String(zapWorkDirFlagName, "/zap/wrk", "a path to the ZAP working directory")
 
+	activeScanAllowlistFile := flag.String("activeScanAllowlistFile", "", "a path to a file listing the hostnames, IP addresses, and CIDR networks that active scans may target")
+	activeScanAllowlist := flag.String("activeScanAllowlist", "", "a comma-separated list of the hostnames, IP addresses, and CIDR networks that active scans may target")
+
+	var configOverrides overrideFlags
+	flag.Var(&configOverrides, "set", "a key=value override of a scan request file value (e.g., context.target=https://localhost); can be repeated")
+
+	dryRun := flag.Bool("dryRun", false, "print the scan plan without starting ZAP")
+	dryRunFormat := flag.String("dryRunFormat", "text", "the format of the scan plan printed by -dryRun; text or json")
+
 	flag.Parse()
 
+	if *dryRunFormat != "text" && *dryRunFormat != "json" {
+		console.Fatalf(invalidConfigurationExitCode, "Flag dryRunFormat should be text or json\n")
+	}
+
 	// tee to stdout for compatibility with `kubectl logs` command
 	f := console.SetLogger("logFile", logFile, true, cannotOpenLogFileExitCode)
 	defer func() {
@@ -98,6 +150,51 @@ func main() {
 		}
 	}()
 
+	logOutput := io.MultiWriter(os.Stdout, f)
+	if *dryRun {
+		// keep stdout for the plan
+		logOutput = io.MultiWriter(os.Stderr, f)
+		log.SetOutput(logOutput)
+	}
+
+	sr := console.ReadFileFlagValue(scanRequestFilePathFlagName, scanRequestFilePathFlag, true, cannotParseConfigurationFileExitCode)
+
+	// command-line overrides take precedence over environment variable overrides
+	overrides := append(zap.EnvironmentConfigOverrides(os.Environ()), configOverrides...)
+	for _, override := range overrides {
+		log.Printf("Overriding scan request file key %s", override.Key)
+	}
+
+	config, err := zap.ParseConfig(sr, *scanMode, overrides...)
+	if err != nil {
+		console.Fatal(cannotParseConfigurationFileExitCode, err)
+	}
+
+	// mask the loaded secrets in the log from now on
+	redactor := config.Redactor()
+	log.SetOutput(redactor.NewWriter(logOutput))
+
+	effectiveConfig, err := config.EffectiveConfig()
+	if err != nil {
+		console.Fatal(cannotParseConfigurationFileExitCode, err)
+	}
+	log.Printf("Effective configuration:\n%s", effectiveConfig)
+
+	if allowlist := readActiveScanAllowlist(activeScanAllowlistFile, activeScanAllowlist); allowlist != nil {
+		config.SetActiveScanAllowlist(allowlist)
+		if err := config.CheckActiveScanTargets(); err != nil {
+			console.Fatal(activeScanTargetNotAllowedExitCode, err)
+		}
+	}
+	if err := config.Validate(*scanMode); err != nil {
+		console.Fatalf(invalidConfigurationExitCode, "cannot configure context because ZAP configuration is invalid:\n%s\n", err.Error())
+	}
+
+	if *dryRun {
+		printPlan(zap.NewPlan(config, *scanMode, *zapApiScanPathFlag, *zapWorkDirFlag), *dryRunFormat)
+		return
+	}
+
 	zapOut, err := os.OpenFile(*zapStdoutLogFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
 	if err != nil {
 		console.Fatalf(cannotOpenZapLogFileExitCode, "Failed to open log file %s", *zapStdoutLogFile)
@@ -117,6 +214,20 @@ func main() {
 		}
 	}()
 
+	zapOutWriter := redactor.NewWriter(io.MultiWriter(os.Stdout, zapOut))
+	zapErrWriter := redactor.NewWriter(io.MultiWriter(os.Stderr, zapErr))
+	flushZapWriters := func() {
+		if err := zapOutWriter.Flush(); err != nil {
+			log.Println(err)
+		}
+		if err := zapErrWriter.Flush(); err != nil {
+			log.Println(err)
+		}
+	}
+	// a fatal error skips deferred calls, and the last partial line of ZAP output often explains it
+	console.AtExit(flushZapWriters)
+	defer flushZapWriters()
+
 	exists, err := exists(*xsltProgram)
 	if !exists {
 		errMsg := fmt.Sprintf("Unable to find xsltProgram at path %s", *xsltProgram)
@@ -126,33 +237,127 @@ func main() {
 		console.Fatal(missingXsltProgramExitCode, errMsg)
 	}
 
-	sr := console.ReadFileFlagValue(scanRequestFilePathFlagName, scanRequestFilePathFlag, true, cannotParseConfigurationFileExitCode)
-
 	zapApiScanPath := console.ReadFileFlagValue(zapApiScanPathFlagName, zapApiScanPathFlag, true, cannotParseConfigurationFileExitCode)
 	zapWorkDir := console.ReadDirectoryFlagValue(zapWorkDirFlagName, zapWorkDirFlag, true, cannotParseConfigurationFileExitCode)
 
-	config, err := zap.ParseConfig(sr, *scanMode)
+	if zap.IsNormalScan(*scanMode) {
+		runScan(zapPath, zapStartupWait, zapOutWriter, zapErrWriter, config, xsltProgram, output)
+	} else {
+		runApiScan(zapApiScanPath, zapWorkDir, zapPath, zapStartupWait, zapOutWriter, zapErrWriter, config, xsltProgram, output)
+	}
+}
+
+// printPlan prints a dry run's scan plan to stdout in the specified format.
+func printPlan(plan *zap.Plan, format string) {
+
+	if format == "text" {
+		fmt.Print(plan.String())
+		return
+	}
+
+	planJSON, err := plan.JSON()
 	if err != nil {
+		console.Fatal(invalidConfigurationExitCode, err)
+	}
+	fmt.Println(planJSON)
+}
+
+// migrate rewrites a scan request file in the current schema, printing a warning for each deprecated key.
+func migrate(args []string) {
+
+	const scanRequestFilePathFlagName = "scanRequestFile"
+
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	scanRequestFilePathFlag := flags.String(scanRequestFilePathFlagName, "", "a path to the scan request file to migrate")
+	output := flags.String("output", "", "a path to the migrated scan request file; the scan request file is rewritten if one is not provided")
+	if err := flags.Parse(args); err != nil {
 		console.Fatal(cannotParseConfigurationFileExitCode, err)
 	}
-	if !config.IsValid(*scanMode) {
-		console.Fatal(invalidConfigurationExitCode, "cannot configure context because ZAP configuration is invalid")
+
+	sr := console.ReadFileFlagValue(scanRequestFilePathFlagName, scanRequestFilePathFlag, true, cannotParseConfigurationFileExitCode)
+
+	content, err := os.ReadFile(sr)
+	if err != nil {
+		console.Fatal(cannotParseConfigurationFileExitCode, err)
 	}
 
-	if zap.IsNormalScan(*scanMode) {
-		runScan(zapPath, zapStartupWait, zapOut, zapErr, config, xsltProgram, output)
-	} else {
-		runApiScan(zapApiScanPath, zapWorkDir, zapPath, zapStartupWait, zapOut, zapErr, config, xsltProgram, output)
+	migratedContent, warnings, err := zap.MigrateRequestFile(content, zap.ConfigFormat(sr, content))
+	if err != nil {
+		console.Fatal(cannotParseConfigurationFileExitCode, err)
+	}
+	for _, warning := range warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+
+	outputPath := *output
+	if outputPath == "" {
+		outputPath = sr
+	}
+	if err := os.WriteFile(outputPath, migratedContent, 0600); err != nil {
+		console.Fatal(cannotParseConfigurationFileExitCode, err)
+	}
+	fmt.Printf("Migrated %s to schema version %d at %s\n", sr, zap.CurrentSchemaVersion, outputPath)
+}
+
+// schema prints the JSON Schema of the scan request file for a scan mode.
+func schema(args []string) {
+
+	flags := flag.NewFlagSet("schema", flag.ExitOnError)
+	scanMode := flags.String("scanMode", "normal", "the type of scan described by the schema; normal or api")
+	output := flags.String("output", "", "a path to the JSON Schema file; the schema is printed if one is not provided")
+	if err := flags.Parse(args); err != nil {
+		console.Fatal(invalidConfigurationExitCode, err)
+	}
+
+	content, err := zap.GenerateJSONSchema(*scanMode)
+	if err != nil {
+		console.Fatal(invalidConfigurationExitCode, err)
+	}
+
+	if *output == "" {
+		fmt.Print(string(content))
+		return
+	}
+	if err := os.WriteFile(*output, content, 0644); err != nil {
+		console.Fatal(invalidConfigurationExitCode, err)
 	}
 }
 
-func initZap(zapPath *string, zapStartupWait *int, outWriter io.Writer, errWriter io.Writer, wg *sync.WaitGroup) (*zaproxy.Interface, chan int) {
+// readActiveScanAllowlist reads the operator-controlled active scan allowlist from the specified file and/or list,
+// returning nil when neither is specified. An empty allowlist allows no active scan targets.
+func readActiveScanAllowlist(allowlistFile *string, allowlist *string) *zap.TargetAllowlist {
+
+	if *allowlistFile == "" && *allowlist == "" {
+		return nil
+	}
+
+	entries := make([]string, 0)
+	if *allowlistFile != "" {
+		log.Printf("Reading active scan allowlist from %s...", *allowlistFile)
+		fileAllowlist, err := os.ReadFile(*allowlistFile)
+		if err != nil {
+			console.Fatal(activeScanTargetNotAllowedExitCode, err)
+		}
+		entries = append(entries, strings.Split(string(fileAllowlist), "\n")...)
+	}
+	if *allowlist != "" {
+		entries = append(entries, strings.Split(*allowlist, ",")...)
+	}
+
+	targetAllowlist, err := zap.ParseTargetAllowlist(entries)
+	if err != nil {
+		console.Fatal(activeScanTargetNotAllowedExitCode, err)
+	}
+	return targetAllowlist
+}
+
+func initZap(zapPath *string, zapStartupWait *int, outWriter io.Writer, errWriter io.Writer, zapOptions []string, wg *sync.WaitGroup) (*zaproxy.Interface, chan int) {
 	apiKey := "api-key"
 	quit := make(chan int)     // channel to keep zap go routine running until it's time to quit ZAP
 	ready := make(chan string) // channel to wait for zap initialization
 
 	wg.Add(1)
-	go zap.RunZap(*zapPath, apiKey, time.Second*time.Duration(*zapStartupWait), outWriter, errWriter, ready, quit, wg)
+	go zap.RunZap(*zapPath, apiKey, time.Second*time.Duration(*zapStartupWait), outWriter, errWriter, zapOptions, ready, quit, wg)
 
 	version, ok := <-ready
 	if !ok {
@@ -169,23 +374,37 @@ func initZap(zapPath *string, zapStartupWait *int, outWriter io.Writer, errWrite
 	return client, quit
 }
 
-func runScan(zapPath *string, zapStartupWait *int, zapOut *os.File, zapErr *os.File, config *zap.Config, xsltProgram *string, output *string) {
+func runScan(zapPath *string, zapStartupWait *int, zapOut io.Writer, zapErr io.Writer, config *zap.Config, xsltProgram *string, output *string) {
 	var wg sync.WaitGroup
 
-	client, quit := initZap(zapPath, zapStartupWait, io.MultiWriter(os.Stdout, zapOut), io.MultiWriter(os.Stderr, zapErr), &wg)
+	client, quit := initZap(zapPath, zapStartupWait, zapOut, zapErr, config.Scope.ZapConfigOptions(), &wg)
 
-	ctx := createContext(client, config, quit, &wg)
+	if config.ScanOptions.Mode != "" {
+		log.Printf("Setting ZAP mode to %s...", config.ScanOptions.Mode)
+		if err := zap.SetMode(client, config.ScanOptions.Mode); err != nil {
+			stopZap(quit, &wg)
+			console.Fatal(setModeFailedExitCode, err)
+		}
+	}
 
-	nodeCnt := runAnonymousSpider(client, config, quit, &wg)
+	contextConfigs := config.GetContexts()
 
-	runAnonymousScan(client, config, ctx, quit, &wg)
+	contexts := make([]*zap.Context, len(contextConfigs))
+	for i, contextConfig := range contextConfigs {
+		contexts[i] = createContext(client, contextConfig, quit, &wg)
+	}
 
-	nodeCnt += runSpiderAndScan(client, config, ctx, quit, &wg)
+	nodeCnt := 0
+	for i, contextConfig := range contextConfigs {
+		nodeCnt += runContext(client, config, contextConfig, contexts[i], quit, &wg)
+	}
 
 	if nodeCnt == 0 {
 		console.Fatalf(noNodesAddedExitCode, "Spider operation(s) added 0 nodes. Is the target URL set correctly?")
 	}
 
+	checkScope(client, config, quit, &wg)
+
 	saveReport(client, config, xsltProgram, output, quit, &wg)
 
 	log.Println("Stopping ZAP...")
@@ -194,9 +413,35 @@ func runScan(zapPath *string, zapStartupWait *int, zapOut *os.File, zapErr *os.F
 	log.Println("ZAP scan completed")
 }
 
-func createContext(client *zaproxy.Interface, config *zap.Config, quit chan int, wg *sync.WaitGroup) *zap.Context {
+// checkScope reports the hosts outside of the scope allowlist that ZAP accessed or attempted to access and, in strict
+// mode, fails the run when there are any.
+func checkScope(client *zaproxy.Interface, config *zap.Config, quit chan int, wg *sync.WaitGroup) {
 
-	log.Println("Creating context...")
+	if !config.Scope.IsEnabled() {
+		return
+	}
+
+	log.Println("Checking for out-of-scope hosts...")
+	hosts, err := zap.FindOutOfScopeHosts(client, config.Scope)
+	if err != nil {
+		stopZap(quit, wg)
+		console.Fatal(outOfScopeTrafficExitCode, err)
+	}
+
+	for _, host := range hosts {
+		log.Printf("Warning: ZAP accessed or attempted to access out-of-scope host %s", host)
+	}
+	log.Printf("Found %d out-of-scope host(s)", len(hosts))
+
+	if config.Scope.Strict && len(hosts) > 0 {
+		stopZap(quit, wg)
+		console.Fatalf(outOfScopeTrafficExitCode, "Out-of-scope hosts were accessed or attempted: %s", strings.Join(hosts, ", "))
+	}
+}
+
+func createContext(client *zaproxy.Interface, config *zap.ContextConfig, quit chan int, wg *sync.WaitGroup) *zap.Context {
+
+	log.Printf("Creating context %s...", config.Context.Name)
 	ctx, err := zap.ConfigureContext(client, config, "")
 	if err != nil {
 		stopZap(quit, wg)
@@ -237,7 +482,85 @@ func createContext(client *zaproxy.Interface, config *zap.Config, quit chan int,
 	return &ctx
 }
 
-func runAnonymousSpider(client *zaproxy.Interface, config *zap.Config, quit chan int, wg *sync.WaitGroup) int {
+// runContext runs the spiders and scans for a context. It returns the number of nodes added by the spiders.
+func runContext(client *zaproxy.Interface, config *zap.Config, contextConfig *zap.ContextConfig, ctx *zap.Context, quit chan int, wg *sync.WaitGroup) int {
+
+	log.Printf("Starting spider and scan of context %s...", ctx.ContextName)
+
+	configureHeaderAuthentication(client, contextConfig, quit, wg)
+
+	stopOAuth2Refresh := configureOAuth2Authentication(client, contextConfig, quit, wg)
+
+	nodeCnt := runAnonymousSpider(client, contextConfig, quit, wg)
+
+	runAnonymousScan(client, config, contextConfig, ctx, quit, wg)
+
+	nodeCnt += runSpiderAndScan(client, config, contextConfig, ctx, quit, wg)
+
+	stopOAuth2Refresh()
+
+	clearAuthenticationHeader(client, contextConfig)
+
+	log.Printf("Spider and scan of context %s completed", ctx.ContextName)
+	return nodeCnt
+}
+
+func configureHeaderAuthentication(client *zaproxy.Interface, config *zap.ContextConfig, quit chan int, wg *sync.WaitGroup) {
+
+	if !config.IsAuthenticationEnabled() || !config.UseHeaderAuthentication() {
+		return
+	}
+
+	log.Println("Configuring authentication header...")
+	if err := zap.ConfigureHeaderAuthentication(client, config); err != nil {
+		stopZap(quit, wg)
+		console.Fatal(headerAuthenticationFailedExitCode, err)
+	}
+	log.Println("Authentication header configured")
+}
+
+// configureOAuth2Authentication fetches an OAuth2 token and starts refreshing it in the background. It returns
+// a function that stops the token refresh.
+func configureOAuth2Authentication(client *zaproxy.Interface, config *zap.ContextConfig, quit chan int, wg *sync.WaitGroup) func() {
+
+	if !config.IsAuthenticationEnabled() || !config.UseOAuth2Authentication() {
+		return func() {}
+	}
+
+	log.Println("Fetching OAuth2 token...")
+	token, err := zap.ConfigureOAuth2Authentication(client, config)
+	if err != nil {
+		stopZap(quit, wg)
+		console.Fatal(oauth2AuthenticationFailedExitCode, err)
+	}
+	log.Println("OAuth2 authentication header configured")
+
+	var refreshWg sync.WaitGroup
+	refreshQuit := make(chan int)
+
+	refreshWg.Add(1)
+	go zap.RefreshOAuth2Authentication(client, config, token, refreshQuit, &refreshWg)
+
+	return func() {
+		close(refreshQuit)
+		refreshWg.Wait()
+	}
+}
+
+// clearAuthenticationHeader removes a context's authentication header so that it is not sent with requests for
+// other contexts.
+func clearAuthenticationHeader(client *zaproxy.Interface, config *zap.ContextConfig) {
+
+	if !config.IsAuthenticationEnabled() || !(config.UseHeaderAuthentication() || config.UseOAuth2Authentication()) {
+		return
+	}
+
+	if err := zap.ClearAuthenticationHeader(client, config); err != nil {
+		log.Println(err)
+	}
+}
+
+func runAnonymousSpider(client *zaproxy.Interface, config *zap.ContextConfig, quit chan int, wg *sync.WaitGroup) int {
 
 	log.Println("Starting spider (anonymous)...")
 	cnt, err := zap.Spider(client, config.Context.Target, config.Context.Name)
@@ -249,59 +572,175 @@ func runAnonymousSpider(client *zaproxy.Interface, config *zap.Config, quit chan
 	return cnt
 }
 
-func runAnonymousScan(client *zaproxy.Interface, config *zap.Config, ctx *zap.Context, quit chan int, wg *sync.WaitGroup) {
+func runAnonymousScan(client *zaproxy.Interface, config *zap.Config, contextConfig *zap.ContextConfig, ctx *zap.Context, quit chan int, wg *sync.WaitGroup) {
 
 	if !config.ScanOptions.RunActiveScan {
 		return
 	}
 
 	log.Println("Starting scan (anonymous)...")
-	if err := zap.Scan(client, config.Context.Target, ctx.ContextID); err != nil {
+	if err := zap.Scan(client, contextConfig.Context.Target, ctx.ContextID); err != nil {
 		stopZap(quit, wg)
 		console.Fatal(anonymousActiveScanFailedExitCode, err)
 	}
 	log.Println("Scan completed")
 }
 
-func runSpiderAndScan(client *zaproxy.Interface, config *zap.Config, ctx *zap.Context, quit chan int, wg *sync.WaitGroup) int {
+func runSpiderAndScan(client *zaproxy.Interface, config *zap.Config, contextConfig *zap.ContextConfig, ctx *zap.Context, quit chan int, wg *sync.WaitGroup) int {
 
 	totalCnt := 0
 	log.Println("Starting spider and scan...")
+
+	restoreLogoutExclusions, err := zap.ExcludeLogoutLinks(client, contextConfig)
+	if err != nil {
+		stopZap(quit, wg)
+		console.Fatal(logoutLinksFailedExitCode, err)
+	}
+
 	for i := range ctx.Users {
 		user := ctx.Users[i]
 
-		if config.Authentication.ForcedUserMode {
+		if contextConfig.Authentication.ForcedUserMode {
 			log.Printf("Forcing user (%s)...", user.Credential.Username)
 			if err := zap.ForceUser(client, ctx.ContextID, user.UserID); err != nil {
 				console.Fatal(authenticatedUserSpiderFailedExitCode, err)
 			}
 		}
 
-		log.Printf("Starting spider (%s)...", user.Credential.Username)
-		cnt, err := zap.SpiderAsUser(client, config.Context.Target, ctx.ContextID, user.UserID)
+		stopTOTPRefresh := configureTOTP(client, contextConfig, ctx, user, quit, wg)
+
+		restoreExclusions, err := zap.ExcludeUserURLs(client, user)
 		if err != nil {
 			stopZap(quit, wg)
-			console.Fatal(authenticatedUserSpiderFailedExitCode, err)
+			console.Fatal(userMetadataFailedExitCode, err)
+		}
+
+		firstAlert, err := zap.CountAlerts(client)
+		if err != nil {
+			stopZap(quit, wg)
+			console.Fatal(userMetadataFailedExitCode, err)
+		}
+
+		loggedOutResponses, err := zap.TrackLoggedOutResponses(client, contextConfig)
+		if err != nil {
+			stopZap(quit, wg)
+			console.Fatal(logoutLinksFailedExitCode, err)
+		}
+
+		startURLs := user.GetStartURLs(contextConfig.Context.Target)
+		for _, startURL := range startURLs {
+			log.Printf("Starting spider (%s) at %s...", user.Credential.Username, startURL)
+			cnt, err := zap.SpiderAsUser(client, startURL, ctx.ContextID, user.UserID)
+			if err != nil {
+				stopZap(quit, wg)
+				console.Fatal(authenticatedUserSpiderFailedExitCode, err)
+			}
+			log.Printf("Spider completed - add %d node(s)", cnt)
+
+			totalCnt += cnt
 		}
-		log.Printf("Spider completed - add %d node(s)", cnt)
 
-		totalCnt += cnt
+		warnLoggedOutResponses(client, user, loggedOutResponses, quit, wg)
 
-		if !config.ScanOptions.RunActiveScan {
-			continue
+		if config.ScanOptions.RunActiveScan {
+			for _, startURL := range startURLs {
+				log.Printf("Starting scan (%s) at %s...", user.Credential.Username, startURL)
+				if err := zap.ScanAsUser(client, startURL, ctx.ContextID, user.UserID); err != nil {
+					stopZap(quit, wg)
+					console.Fatal(authenticatedUserActiveScanFailedExitCode, err)
+				}
+				log.Println("Scan completed")
+			}
+		}
+
+		if user.Credential.Metadata.Role != "" {
+			log.Printf("Labeling alerts with role %s (%s)...", user.Credential.Metadata.Role, user.Credential.Username)
+			if err := zap.LabelAlerts(client, user, firstAlert); err != nil {
+				stopZap(quit, wg)
+				console.Fatal(userMetadataFailedExitCode, err)
+			}
 		}
 
-		log.Printf("Starting scan (%s)...", user.Credential.Username)
-		if err := zap.ScanAsUser(client, config.Context.Target, ctx.ContextID, user.UserID); err != nil {
+		if err := restoreExclusions(); err != nil {
 			stopZap(quit, wg)
-			console.Fatal(authenticatedUserActiveScanFailedExitCode, err)
+			console.Fatal(userMetadataFailedExitCode, err)
+		}
+
+		stopTOTPRefresh()
+	}
+
+	if err := restoreLogoutExclusions(); err != nil {
+		stopZap(quit, wg)
+		console.Fatal(logoutLinksFailedExitCode, err)
+	}
+
+	if contextConfig.Authentication.ForcedUserMode && len(ctx.Users) > 0 {
+		// disable forced user mode so that it does not apply to other contexts
+		if err := zap.ForceUser(client, ctx.ContextID, ""); err != nil {
+			console.Fatal(authenticatedUserSpiderFailedExitCode, err)
 		}
-		log.Println("Scan completed")
 	}
 	log.Println("Spider and scan completed")
 	return totalCnt
 }
 
+// warnLoggedOutResponses logs a warning naming the link that most likely ended the user's session when the user's
+// spider received repeated logged-out responses.
+func warnLoggedOutResponses(client *zaproxy.Interface, user zap.User, loggedOutResponses func() ([]zap.LoggedOutResponse, error), quit chan int, wg *sync.WaitGroup) {
+
+	responses, err := loggedOutResponses()
+	if err != nil {
+		stopZap(quit, wg)
+		console.Fatal(logoutLinksFailedExitCode, err)
+	}
+	if len(responses) < zap.LoggedOutWarningThreshold {
+		return
+	}
+
+	urls := make([]string, len(responses))
+	for i, response := range responses {
+		urls[i] = response.URL
+	}
+
+	link, err := zap.FindLoggedOutLink(client, responses)
+	if err != nil {
+		log.Println(err)
+	}
+	if link == "" {
+		log.Printf("Warning: the logged-out indicator matched %d responses while spidering as %s (%s); consider excluding the link that ends the session",
+			len(responses), user.Credential.Username, strings.Join(urls, ", "))
+		return
+	}
+	log.Printf("Warning: the logged-out indicator matched %d responses while spidering as %s (%s) after a request to %s; consider excluding it if it ends the session",
+		len(responses), user.Credential.Username, strings.Join(urls, ", "), link)
+}
+
+// configureTOTP sets the {%totp%} placeholder value for the specified user and keeps it current in the
+// background. It returns a function that stops the TOTP refresh.
+func configureTOTP(client *zaproxy.Interface, config *zap.ContextConfig, ctx *zap.Context, user zap.User, quit chan int, wg *sync.WaitGroup) func() {
+
+	if user.Credential.TotpSecret == "" {
+		return func() {}
+	}
+
+	log.Printf("Configuring TOTP (%s)...", user.Credential.Username)
+	if err := zap.ConfigureTOTP(client, config, ctx, user.Credential); err != nil {
+		stopZap(quit, wg)
+		console.Fatal(totpFailedExitCode, err)
+	}
+
+	var refreshWg sync.WaitGroup
+	refreshQuit := make(chan int)
+
+	refreshWg.Add(1)
+	go zap.RefreshTOTP(client, config, ctx, user.Credential, refreshQuit, &refreshWg)
+
+	return func() {
+		close(refreshQuit)
+		refreshWg.Wait()
+	}
+}
+
 func saveReport(client *zaproxy.Interface, config *zap.Config, xsltProgram *string, output *string, quit chan int, wg *sync.WaitGroup) {
 
 	log.Println("Saving report...")
@@ -310,94 +749,89 @@ func saveReport(client *zaproxy.Interface, config *zap.Config, xsltProgram *stri
 		stopZap(quit, wg)
 		console.Fatal(saveReportFailedExitCode, err)
 	}
+
+	if err := zap.GroupReportByTarget(*xsltProgram, *output, config); err != nil {
+		stopZap(quit, wg)
+		console.Fatal(saveReportFailedExitCode, err)
+	}
+
+	// request and response evidence can include a credential or token
+	if err := config.Redactor().RedactFile(*output); err != nil {
+		stopZap(quit, wg)
+		console.Fatal(saveReportFailedExitCode, err)
+	}
 	log.Println("Report saved")
 }
 
-func runApiScan(zapApiScanPath string, zapWorkDir string, zapPath *string, zapStartupWait *int, zapOut *os.File, zapErr *os.File, config *zap.Config, xsltProgram *string, output *string) {
-	// The current ZAP release (2.11.1) requires some of the file path args to be given relative to
-	// the /zap/wrk/ dir. Of the arguments that runApiScan uses, this includes the context file (-n),
-	// config file (-c), and report output file (-x). Future releases of ZAP will not have this
-	// limitation and will allow fully qualified paths, including paths outside of the /zap/wrk/ dir.
-	reportFile := filepath.Join(zapWorkDir, "report.xml")
-	reportFileArg := "report.xml"
-	apiScanArgs := []string{
-		zapApiScanPath,
-		"-t", config.Context.Target,
-		"-f", config.Context.Format,
-		"-x", reportFileArg,
-	}
-
-	if config.IsContextFileRequired() {
-		contextFile := filepath.Join(zapWorkDir, "context.xml")
-		contextFileArg := "context.xml"
-
-		// this file name/location corresponds to the one in auth_script_hook.py
-		authScriptFile := filepath.Join(zapWorkDir, "authScript")
-		authHooksFile := filepath.Join(zapWorkDir, "auth_script_hook.py")
-
-		ctx := createApiScanContextFile(contextFile, authScriptFile, zapPath, zapStartupWait, config)
-		if config.IsContextAuthRequired() {
-			if config.UseScriptAuthentication() {
-				// make sure the authScript was created
-				authExists, err := exists(authScriptFile)
-				if !authExists {
-					errMsg := "authScript does not exist"
-					if err != nil {
-						errMsg += " - " + err.Error()
-					}
-					console.Fatal(apiScanAuthScriptErrorExitCode, errMsg)
-				}
-				// add the auth_script_hook, which loads the authScript in the api-scan ZAP daemon after it starts
-				apiScanArgs = append(apiScanArgs, "--hook", authHooksFile)
-			}
-			apiScanArgs = append(apiScanArgs, "-U", ctx.Users[0].Credential.Username)
-		}
-		apiScanArgs = append(apiScanArgs, "-n", contextFileArg)
-	}
+func runApiScan(zapApiScanPath string, zapWorkDir string, zapPath *string, zapStartupWait *int, zapOut io.Writer, zapErr io.Writer, config *zap.Config, xsltProgram *string, output *string) {
+	// an API scan has a single context
+	contextConfig := config.GetContexts()[0]
+
+	reportFile := filepath.Join(zapWorkDir, zap.ApiScanReportFileName)
 
-	if config.Context.OpenApiHostnameOverride != "" {
-		apiScanArgs = append(apiScanArgs, "-O", config.Context.OpenApiHostnameOverride)
+	checkApiScope(contextConfig, config, zapWorkDir)
+
+	if err := config.CheckApiDefinitionActiveScanTargets(contextConfig, zapWorkDir); err != nil {
+		console.Fatal(activeScanTargetNotAllowedExitCode, err)
 	}
 
-	if !config.ScanOptions.RunActiveScan {
-		apiScanArgs = append(apiScanArgs, "-S")
+	// a fatal error must not leave the credentials in the context, authentication script, and OAuth2 token files on disk
+	console.AtExit(func() { deleteApiScanCredentialFiles(zapWorkDir) })
+
+	if contextConfig.IsContextFileRequired() {
+		contextFile := filepath.Join(zapWorkDir, zap.ApiScanContextFileName)
+		authScriptFile := filepath.Join(zapWorkDir, zap.ApiScanAuthScriptFileName)
+
+		if !contextConfig.IsContextExportRequired() {
+			writeApiScanContextFile(contextFile, contextConfig)
+		} else {
+			createApiScanContextFile(contextFile, authScriptFile, zapPath, zapStartupWait, contextConfig)
+		}
+		if contextConfig.IsContextAuthRequired() && contextConfig.UseScriptAuthentication() {
+			// make sure the authScript was created
+			authExists, err := exists(authScriptFile)
+			if !authExists {
+				errMsg := "authScript does not exist"
+				if err != nil {
+					errMsg += " - " + err.Error()
+				}
+				console.Fatal(apiScanAuthScriptErrorExitCode, errMsg)
+			}
+		}
 	}
 
 	if config.ScanOptions.ApiScanConfigContent != "" {
-		configFile := filepath.Join(zapWorkDir, "config.txt")
-		configFileArg := "config.txt"
+		configFile := filepath.Join(zapWorkDir, zap.ApiScanConfigFileName)
 		err := writeConfigFile(configFile, config.ScanOptions.ApiScanConfigContent)
 		if err != nil {
 			console.Fatal(apiScanConfigFileErrorExitCode, "Unable to write ZAP API scan config file")
 		}
-		apiScanArgs = append(apiScanArgs, "-c", configFileArg)
 	}
 
+	authHeaderValue := ""
+	if contextConfig.IsAuthenticationEnabled() && contextConfig.UseHeaderAuthentication() {
+		authHeaderValue = contextConfig.GetCredentials()[0].Password
+	}
+
+	stopOAuth2Refresh := startApiScanOAuth2Refresh(contextConfig, zapWorkDir)
+
 	// for backward compatibility with zap container image v1.52.0 and earlier, rely on the PATH environment variable for the
 	// location of python3, which will be either the global python (/usr/bin/python) used with v1.52.0 and earlier or the
 	// one in a virtual environment (at /opt/python/bin/python3) required for v1.53.0 and later
 	cmd := exec.Command(
-		"python3", append(apiScanArgs, config.ScanOptions.ApiScanOptions...)...,
+		"python3", zap.ApiScanArguments(config, zapApiScanPath, zapWorkDir)...,
 	)
-	cmd.Stdout = io.MultiWriter(os.Stdout, zapOut)
-	cmd.Stderr = io.MultiWriter(os.Stderr, zapErr)
-
-	if config.IsAuthenticationEnabled() && config.UseHeaderAuthentication() {
-		env := fmt.Sprintf("ZAP_AUTH_HEADER_VALUE=%s", config.GetCredentials()[0].Password)
-		cmd.Env = append(os.Environ(), env)
-		if config.HeaderAuthentication.AuthHeaderName != "" {
-			env = fmt.Sprintf("ZAP_AUTH_HEADER=%s", config.HeaderAuthentication.AuthHeaderName)
-			cmd.Env = append(cmd.Env, env)
-		}
-		if config.HeaderAuthentication.AuthHeaderSite != "" {
-			env = fmt.Sprintf("ZAP_AUTH_HEADER_SITE=%s", config.HeaderAuthentication.AuthHeaderSite)
-			cmd.Env = append(cmd.Env, env)
-		}
-	}
+	cmd.Stdout = zapOut
+	cmd.Stderr = zapErr
+	cmd.Env = append(os.Environ(), zap.ApiScanEnvironment(contextConfig, authHeaderValue, zapWorkDir)...)
 
 	log.Println("Starting scan (API)...")
 
 	err := cmd.Run()
+
+	stopOAuth2Refresh()
+	deleteApiScanCredentialFiles(zapWorkDir)
+
 	if err != nil {
 		exitErr, _ := err.(*exec.ExitError)
 		exitCode := exitErr.ExitCode()
@@ -416,15 +850,89 @@ func runApiScan(zapApiScanPath string, zapWorkDir string, zapPath *string, zapSt
 	if err != nil {
 		console.Fatal(copyReportFailedExitCode, err)
 	}
+	if err := zap.SecureDelete(reportFile); err != nil {
+		log.Println(err)
+	}
 
 	log.Println("Applying report template...")
 	err = zap.ApplyXslt(*xsltProgram, *output, config.ReportOptions.MinRiskThreshold, config.ReportOptions.MinConfThreshold)
 	if err != nil {
 		console.Fatal(applyXsltFailedExitCode, err)
 	}
+
+	// request and response evidence can include a credential or token
+	if err := config.Redactor().RedactFile(*output); err != nil {
+		console.Fatal(applyXsltFailedExitCode, err)
+	}
 	log.Println("Teport template applied")
 }
 
+// startApiScanOAuth2Refresh fetches an OAuth2 token for an API scan and writes it to the file that
+// auth_script_hook.py reads, refreshing it before it expires. It returns a function that stops the refresh.
+func startApiScanOAuth2Refresh(config *zap.ContextConfig, zapWorkDir string) func() {
+
+	if !config.IsAuthenticationEnabled() || !config.UseOAuth2Authentication() {
+		return func() {}
+	}
+
+	log.Println("Fetching OAuth2 token...")
+	token, err := zap.FetchOAuth2Token(config, config.GetCredentials()[0])
+	if err != nil {
+		console.Fatal(oauth2AuthenticationFailedExitCode, err)
+	}
+
+	tokenFile := filepath.Join(zapWorkDir, zap.ApiScanOAuth2TokenFileName)
+	if err := zap.WriteOAuth2TokenFile(tokenFile, token); err != nil {
+		console.Fatal(oauth2AuthenticationFailedExitCode, err)
+	}
+	log.Println("OAuth2 token written for auth_script_hook.py")
+
+	var refreshWg sync.WaitGroup
+	refreshQuit := make(chan int)
+
+	refreshWg.Add(1)
+	go zap.RefreshOAuth2TokenFile(config, token, tokenFile, refreshQuit, &refreshWg)
+
+	return func() {
+		close(refreshQuit)
+		refreshWg.Wait()
+	}
+}
+
+// deleteApiScanCredentialFiles securely deletes the context, authentication script, and OAuth2 token files, which
+// include credentials, from the ZAP working directory.
+func deleteApiScanCredentialFiles(zapWorkDir string) {
+	for _, file := range []string{zap.ApiScanContextFileName, zap.ApiScanAuthScriptFileName, zap.ApiScanOAuth2TokenFileName} {
+		if err := zap.SecureDelete(filepath.Join(zapWorkDir, file)); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// checkApiScope reports the hosts outside of the scope allowlist that the API definition directs ZAP to access and,
+// in strict mode, fails the run when there are any.
+func checkApiScope(contextConfig *zap.ContextConfig, config *zap.Config, zapWorkDir string) {
+
+	if !config.Scope.IsEnabled() {
+		return
+	}
+
+	log.Println("Checking the API definition for out-of-scope hosts...")
+	hosts, err := zap.FindApiDefinitionOutOfScopeHosts(contextConfig, config.Scope, zapWorkDir)
+	if err != nil {
+		console.Fatal(outOfScopeTrafficExitCode, err)
+	}
+
+	for _, host := range hosts {
+		log.Printf("Warning: the API definition directs ZAP to access out-of-scope host %s", host)
+	}
+	log.Printf("Found %d out-of-scope host(s)", len(hosts))
+
+	if config.Scope.Strict && len(hosts) > 0 {
+		console.Fatalf(outOfScopeTrafficExitCode, "The API definition directs ZAP to access out-of-scope hosts: %s", strings.Join(hosts, ", "))
+	}
+}
+
 func copyFile(srcPath string, destPath string) error {
 
 	src, err := os.Open(srcPath)
@@ -454,12 +962,12 @@ func copyFile(srcPath string, destPath string) error {
 }
 
 // launch and configure a ZAP instance, then export the context file and shut it down
-func createApiScanContextFile(contextFile string, authScriptFile string, zapPath *string, zapStartupWait *int, config *zap.Config) zap.Context {
+func createApiScanContextFile(contextFile string, authScriptFile string, zapPath *string, zapStartupWait *int, config *zap.ContextConfig) zap.Context {
 	log.Println("Creating ZAP context file")
 
 	var wg sync.WaitGroup
 
-	client, quit := initZap(zapPath, zapStartupWait, ioutil.Discard, ioutil.Discard, &wg)
+	client, quit := initZap(zapPath, zapStartupWait, ioutil.Discard, ioutil.Discard, nil, &wg)
 
 	log.Println("Creating context...")
 	ctx, err := zap.ConfigureContext(client, config, authScriptFile)
@@ -482,6 +990,17 @@ func createApiScanContextFile(contextFile string, authScriptFile string, zapPath
 	return ctx
 }
 
+// write a user-supplied context file, which needs no credentials applied, without launching ZAP
+func writeApiScanContextFile(contextFile string, config *zap.ContextConfig) {
+	log.Println("Writing ZAP context file")
+
+	if err := writeConfigFile(contextFile, config.GetContextFileContent()); err != nil {
+		console.Fatal(createContextFailedExitCode, err)
+	}
+
+	log.Println("ZAP context file written")
+}
+
 func writeConfigFile(configFile string, configText string) error {
 	f, err := os.Create(configFile)
 	if err != nil {
@@ -9,12 +9,41 @@ import (
 	"net/url"
 	"os"
 	"strings"
+	"sync"
 )
 
+var (
+	exitFuncsMutex sync.Mutex
+	exitFuncs      []func()
+)
+
+// AtExit registers a function that Fatal and Fatalf call before ending a program, which skips deferred calls.
+// Functions run in the reverse order of their registration.
+func AtExit(f func()) {
+	exitFuncsMutex.Lock()
+	defer exitFuncsMutex.Unlock()
+	exitFuncs = append(exitFuncs, f)
+}
+
+// runExitFuncs calls and removes the functions registered with AtExit, so a function that ends the program
+// cannot run them again.
+func runExitFuncs() {
+
+	exitFuncsMutex.Lock()
+	funcs := exitFuncs
+	exitFuncs = nil
+	exitFuncsMutex.Unlock()
+
+	for i := len(funcs) - 1; i >= 0; i-- {
+		funcs[i]()
+	}
+}
+
 // Fatal terminates a program with a specific exit code and zero or more messages.
 func Fatal(exitCode int, v ...interface{}) {
 	log.Print(v...)
 	log.Printf("Program exiting with exit code %d", exitCode)
+	runExitFuncs()
 	os.Exit(exitCode)
 }
 
@@ -23,6 +52,7 @@ func Fatal(exitCode int, v ...interface{}) {
 func Fatalf(exitCode int, format string, v ...interface{}) {
 	log.Printf(format, v...)
 	fmt.Fprintf(os.Stderr, format, v...)
+	runExitFuncs()
 	os.Exit(exitCode)
 }
 
@@ -62,3 +62,17 @@ func TestReadStringCollectionValueWithEmptyString(t *testing.T) {
 
 	assert.IntsAreEqual(t, 0, len(actual))
 }
+
+func TestRunExitFuncs(t *testing.T) {
+
+	calls := make([]string, 0)
+	AtExit(func() { calls = append(calls, "first") })
+	AtExit(func() { calls = append(calls, "second") })
+
+	runExitFuncs()
+	runExitFuncs()
+
+	assert.IntsAreEqual(t, 2, len(calls))
+	assert.StringsAreEqual(t, "second", calls[0])
+	assert.StringsAreEqual(t, "first", calls[1])
+}
@@ -0,0 +1,97 @@
+package zap
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/zaproxy/zap-api-go/zap"
+)
+
+// The attribute regexes start at whitespace so that attributes such as data-type and data-name do not match.
+var (
+	inputElementRegex  = regexp.MustCompile(`(?is)<input\b[^>]*>`)
+	inputTypeRegex     = regexp.MustCompile(`(?is)\stype\s*=\s*["']?hidden["'\s/>]`)
+	inputNameRegex     = regexp.MustCompile(`(?is)\sname\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>/]+))`)
+	acsrfFieldNameHint = regexp.MustCompile(`(?i)csrf|xsrf|token|nonce|authenticity|verification`)
+)
+
+// DiscoverAntiCrossSiteRequestForgeryFields fetches a login form page and returns the names of hidden input
+// fields that look like anti-CSRF tokens.
+// It returns an error if the page cannot be fetched.
+func DiscoverAntiCrossSiteRequestForgeryFields(pageURL string) ([]string, error) {
+
+	client := http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(pageURL)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			log.Println(err)
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to fetch login form page %s (status code %d)", pageURL, resp.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return findAntiCrossSiteRequestForgeryFields(string(body)), nil
+}
+
+func findAntiCrossSiteRequestForgeryFields(html string) []string {
+	names := make([]string, 0)
+	for _, input := range inputElementRegex.FindAllString(html, -1) {
+		if !inputTypeRegex.MatchString(input) {
+			continue
+		}
+		match := inputNameRegex.FindStringSubmatch(input)
+		if match == nil {
+			continue
+		}
+		name := match[1] + match[2] + match[3]
+		if acsrfFieldNameHint.MatchString(name) {
+			names = appendUnique(names, name)
+		}
+	}
+	return names
+}
+
+// addAntiCrossSiteRequestForgeryTokens registers token names that may not be included in ZAP's default list -
+// adding duplicate tokens appears to be a no-op.
+func addAntiCrossSiteRequestForgeryTokens(names []string, zap *zap.Interface) error {
+	for _, name := range names {
+		if _, err := (*zap).Acsrf().AddOptionToken(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func appendUnique(values []string, newValues ...string) []string {
+	for _, n := range newValues {
+		n = strings.TrimSpace(n)
+		if n == "" {
+			continue
+		}
+		found := false
+		for _, v := range values {
+			if v == n {
+				found = true
+				break
+			}
+		}
+		if !found {
+			values = append(values, n)
+		}
+	}
+	return values
+}
@@ -0,0 +1,52 @@
+package zap
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/codedx/codedx-add-ins/pkg/assert"
+)
+
+const loginForm = `<html><body>
+<form method="post" action="/login">
+  <input type="hidden" name="csrf_token" value="a1">
+  <input type='hidden' value='b2' name='__RequestVerificationToken'/>
+  <INPUT TYPE=HIDDEN NAME=nonce VALUE=c3>
+  <input type="hidden" name="returnUrl" value="/">
+  <input type="text" name="username">
+  <input type="password" name="password">
+  <input type="hidden" name="csrf_token" value="a1">
+</form>
+</body></html>`
+
+func TestDiscoverAntiCrossSiteRequestForgeryFields(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, loginForm)
+	}))
+	defer server.Close()
+
+	names, err := DiscoverAntiCrossSiteRequestForgeryFields(server.URL)
+	assert.NilError(t, err)
+	assert.StringsAreEqual(t, "csrf_token;__RequestVerificationToken;nonce", strings.Join(names, ";"))
+}
+
+func TestFindAntiCrossSiteRequestForgeryFieldsIgnoresDataAttributes(t *testing.T) {
+
+	html := `<input data-name="token_label" name="authenticity_token" data-type="text" type="hidden" value="a1">
+<input type="hidden" aria-name="nonce" name="page" value="2">
+<input data-type="hidden" name="csrf_visible" value="3">`
+
+	assert.StringsAreEqual(t, "authenticity_token", strings.Join(findAntiCrossSiteRequestForgeryFields(html), ";"))
+}
+
+func TestAntiCrossSiteRequestForgeryFieldNames(t *testing.T) {
+
+	formAuth := formAuthentication{
+		FormAntiCrossSiteRequestForgeryFieldNames: []string{"token1", "token2", "token1", ""},
+	}
+	assert.StringsAreEqual(t, "token1;token2", strings.Join(formAuth.antiCrossSiteRequestForgeryFieldNames(), ";"))
+}
@@ -0,0 +1,64 @@
+package zap
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/zaproxy/zap-api-go/zap"
+)
+
+type alertFilter struct {
+	RuleID               int    // the ID of the ZAP scan rule raising the alert
+	URLRegularExpression string // optional regular expression matching the alert URL
+	Parameter            string // optional alert parameter
+	Evidence             string // optional alert evidence
+	NewRisk              string // one of False Positive, Informational, Low, Medium, or High
+}
+
+// alertFilterRiskLevels maps alert filter risk names to ZAP alert filter levels.
+var alertFilterRiskLevels = map[string]string{
+	"False Positive": "-1",
+	"Informational":  "0",
+	"Low":            "1",
+	"Medium":         "2",
+	"High":           "3",
+}
+
+func (f alertFilter) newLevel() (string, bool) {
+	for name, level := range alertFilterRiskLevels {
+		if strings.EqualFold(name, strings.TrimSpace(f.NewRisk)) {
+			return level, true
+		}
+	}
+	return "", false
+}
+
+// addAlertFilters adds context alert filters that change the risk of matching alerts, so that suppressed
+// alerts remain in the report with their new risk (or as false positives) instead of being dropped.
+func addAlertFilters(filters []alertFilter, zap *zap.Interface, ctx *Context) error {
+	for _, filter := range filters {
+
+		newLevel, _ := filter.newLevel()
+		urlIsRegex := strconv.FormatBool(filter.URLRegularExpression != "")
+
+		result, err := (*zap).AlertFilter().AddAlertFilter(ctx.ContextID,
+			strconv.Itoa(filter.RuleID),
+			newLevel,
+			filter.URLRegularExpression,
+			urlIsRegex,
+			filter.Parameter,
+			"true",
+			"false",
+			"",
+			"false",
+			filter.Evidence,
+			"false")
+		if err != nil {
+			return err
+		}
+		if _, err := getZapResult("Result", result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
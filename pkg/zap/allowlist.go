@@ -0,0 +1,101 @@
+package zap
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// TargetAllowlist holds the operator-controlled hostnames and networks that active scans may target.
+type TargetAllowlist struct {
+	hosts    []string
+	networks []*net.IPNet
+	lookupIP func(host string) ([]net.IP, error)
+}
+
+// ParseTargetAllowlist creates an allowlist from hostnames, IP addresses, and CIDR networks. A hostname beginning
+// with *. allows any of its subdomains, and entries beginning with # are ignored.
+func ParseTargetAllowlist(entries []string) (*TargetAllowlist, error) {
+
+	allowlist := &TargetAllowlist{lookupIP: net.LookupIP}
+	for _, entry := range entries {
+
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" || strings.HasPrefix(entry, "#") {
+			continue
+		}
+
+		if strings.Contains(entry, "/") {
+			_, network, err := net.ParseCIDR(entry)
+			if err != nil {
+				return nil, fmt.Errorf("unable to parse allowlist network %s: %s", entry, err.Error())
+			}
+			allowlist.networks = append(allowlist.networks, network)
+			continue
+		}
+
+		if ip := net.ParseIP(entry); ip != nil {
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 8 * net.IPv4len
+			}
+			allowlist.networks = append(allowlist.networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		allowlist.hosts = append(allowlist.hosts, strings.TrimSuffix(entry, "."))
+	}
+	return allowlist, nil
+}
+
+// CheckHost returns an error when a host is neither listed by name nor resolves only to addresses in listed networks.
+func (a *TargetAllowlist) CheckHost(host string) error {
+
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.TrimSuffix(strings.Trim(host, "[]"), "."))
+
+	for _, allowedHost := range a.hosts {
+		if host == allowedHost || (strings.HasPrefix(allowedHost, "*.") && strings.HasSuffix(host, allowedHost[1:])) {
+			return nil
+		}
+	}
+
+	ips := []net.IP{net.ParseIP(host)}
+	if ips[0] == nil {
+		var err error
+		if ips, err = a.lookupIP(host); err != nil {
+			return fmt.Errorf("unable to resolve active scan target %s: %s", host, err.Error())
+		}
+	}
+
+	// every address must be allowed so that a DNS change cannot redirect the scan
+	for _, ip := range ips {
+		if !a.containsIP(ip) {
+			return fmt.Errorf("active scan target %s (%s) is not on the target allowlist", host, ip.String())
+		}
+	}
+	return nil
+}
+
+// CheckTarget returns an error when the host of a target URL with an http or https scheme is not allowed. Other
+// values, such as a path to an API definition file, are allowed; see CheckApiDefinitionActiveScanTargets.
+func (a *TargetAllowlist) CheckTarget(target string) error {
+	u, err := url.Parse(target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil
+	}
+	return a.CheckHost(u.Host)
+}
+
+func (a *TargetAllowlist) containsIP(ip net.IP) bool {
+	for _, network := range a.networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package zap
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/codedx/codedx-add-ins/pkg/assert"
+)
+
+func testTargetAllowlist(t *testing.T, entries ...string) *TargetAllowlist {
+	allowlist, err := ParseTargetAllowlist(entries)
+	assert.NilError(t, err)
+
+	allowlist.lookupIP = func(host string) ([]net.IP, error) {
+		switch host {
+		case "staging.example.com":
+			return []net.IP{net.ParseIP("10.1.2.3")}, nil
+		case "split.example.com":
+			return []net.IP{net.ParseIP("10.1.2.4"), net.ParseIP("203.0.113.7")}, nil
+		case "www.example.com":
+			return []net.IP{net.ParseIP("203.0.113.8")}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+	return allowlist
+}
+
+func TestTargetAllowlistCheckHost(t *testing.T) {
+
+	allowlist := testTargetAllowlist(t, "# test hosts", "app.test", "*.qa.example.com", "10.0.0.0/8", "192.0.2.1", "")
+
+	assert.NilError(t, allowlist.CheckHost("app.test"))
+	assert.NilError(t, allowlist.CheckHost("web.qa.example.com:8443"))
+	assert.NilError(t, allowlist.CheckHost("staging.example.com"))
+	assert.NilError(t, allowlist.CheckHost("192.0.2.1:80"))
+
+	assert.NotNil(t, allowlist.CheckHost("www.example.com"))
+	assert.NotNil(t, allowlist.CheckHost("split.example.com"))
+	assert.NotNil(t, allowlist.CheckHost("unknown.example.com"))
+	assert.NotNil(t, allowlist.CheckHost("192.0.2.2"))
+
+	assert.NilError(t, allowlist.CheckTarget("https://staging.example.com/app"))
+	assert.NilError(t, allowlist.CheckTarget("openapi.json"))
+	assert.NotNil(t, allowlist.CheckTarget("https://www.example.com/"))
+
+	_, err := ParseTargetAllowlist([]string{"10.0.0.0/33"})
+	assert.NotNil(t, err)
+}
+
+func TestCheckActiveScanTargets(t *testing.T) {
+
+	cfg := Config{}
+	cfg.Context.Name = "Context"
+	cfg.Context.Target = "https://www.example.com/"
+	cfg.SetActiveScanAllowlist(testTargetAllowlist(t, "10.0.0.0/8"))
+
+	assert.NilError(t, cfg.CheckActiveScanTargets())
+	assert.True(t, cfg.IsValid("normal"))
+
+	cfg.ScanOptions.RunActiveScan = true
+	assert.NotNil(t, cfg.CheckActiveScanTargets())
+	assert.False(t, cfg.IsValid("normal"))
+
+	cfg.Context.Target = "https://staging.example.com/"
+	assert.True(t, cfg.IsValid("normal"))
+}
+
+func TestCheckActiveScanTargetsStartURLs(t *testing.T) {
+
+	cfg := Config{}
+	cfg.Context.Name = "Context"
+	cfg.Context.Target = "https://staging.example.com/"
+	cfg.ScanOptions.RunActiveScan = true
+	cfg.credentials = Credentials{{Username: "admin", Password: "password"}}
+	cfg.SetActiveScanAllowlist(testTargetAllowlist(t, "10.0.0.0/8"))
+	assert.NilError(t, cfg.CheckActiveScanTargets())
+
+	cfg.credentials[0].Metadata.StartURLs = []string{"https://staging.example.com/admin", "https://www.example.com/admin"}
+	assert.NotNil(t, cfg.CheckActiveScanTargets())
+}
+
+func TestCheckApiDefinitionActiveScanTargets(t *testing.T) {
+
+	zapWorkDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(zapWorkDir, "openapi.json"), []byte(`{"openapi": "3.0.0", "servers": [{"url": "https://www.example.com/v1"}]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(zapWorkDir, "relative.json"), []byte(`{"openapi": "3.0.0", "servers": [{"url": "/v1"}]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := Config{}
+	cfg.Context.Name = "Context"
+	cfg.Context.Target = "openapi.json"
+	cfg.Context.Format = "openapi"
+	cfg.SetActiveScanAllowlist(testTargetAllowlist(t, "10.0.0.0/8"))
+
+	ctx := cfg.GetContexts()[0]
+	assert.NilError(t, cfg.CheckApiDefinitionActiveScanTargets(ctx, zapWorkDir))
+
+	cfg.ScanOptions.RunActiveScan = true
+	assert.NotNil(t, cfg.CheckApiDefinitionActiveScanTargets(ctx, zapWorkDir))
+
+	cfg.Context.Target = "relative.json"
+	assert.NotNil(t, cfg.CheckApiDefinitionActiveScanTargets(ctx, zapWorkDir))
+
+	cfg.Context.OpenApiHostnameOverride = "staging.example.com"
+	assert.NilError(t, cfg.CheckApiDefinitionActiveScanTargets(ctx, zapWorkDir))
+	assert.NilError(t, cfg.CheckActiveScanTargets())
+}
@@ -0,0 +1,97 @@
+package zap
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// soapAddressRegex matches the endpoint locations of a WSDL document's services.
+var soapAddressRegex = regexp.MustCompile(`(?i)<[\w.-]*:?address\s[^>]*location\s*=\s*["']([^"']+)["']`)
+
+// isApiDefinitionFile reports whether an API scan's target is a path to an API definition file instead of a URL.
+func (c *ContextConfig) isApiDefinitionFile() bool {
+	u, err := url.Parse(c.Context.Target)
+	return err != nil || (u.Scheme != "http" && u.Scheme != "https")
+}
+
+// ApiDefinitionHosts returns the hosts that the API definition of an API scan's context directs ZAP to access: the
+// openApiHostnameOverride when there is one, the host of a target URL, or the server hosts of a definition file,
+// which zap-api-scan reads relative to the ZAP working directory. The servers of a definition at a target URL are
+// unknown until ZAP fetches it, so only the target host is returned for it.
+// It returns an error if the definition file cannot be read.
+func ApiDefinitionHosts(cfg *ContextConfig, zapWorkDir string) ([]string, error) {
+
+	if cfg.Context.OpenApiHostnameOverride != "" {
+		return []string{cfg.Context.OpenApiHostnameOverride}, nil
+	}
+
+	if !cfg.isApiDefinitionFile() {
+		u, _ := url.Parse(cfg.Context.Target)
+		return []string{u.Host}, nil
+	}
+
+	definitionPath := cfg.Context.Target
+	if !filepath.IsAbs(definitionPath) {
+		definitionPath = filepath.Join(zapWorkDir, definitionPath)
+	}
+
+	content, err := os.ReadFile(definitionPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read API definition %s: %s", cfg.Context.Target, err.Error())
+	}
+	return readApiDefinitionHosts(content, cfg.Context.Format)
+}
+
+// readApiDefinitionHosts returns the hosts of the servers that an OpenAPI (or Swagger) definition or the service
+// endpoints that a WSDL document specify. Relative server URLs have no host.
+func readApiDefinitionHosts(content []byte, format string) ([]string, error) {
+
+	hosts := make([]string, 0)
+
+	if format == "soap" {
+		for _, match := range soapAddressRegex.FindAllSubmatch(content, -1) {
+			if u, err := url.Parse(string(match[1])); err == nil && u.Host != "" {
+				hosts = append(hosts, u.Host)
+			}
+		}
+		return hosts, nil
+	}
+
+	if format != "openapi" {
+		return hosts, nil
+	}
+
+	var definition struct {
+		Host    string `yaml:"host"` // Swagger 2.0
+		Servers []struct {
+			URL       string `yaml:"url"`
+			Variables map[string]struct {
+				Default string `yaml:"default"`
+			} `yaml:"variables"`
+		} `yaml:"servers"` // OpenAPI 3
+	}
+	// a JSON document is also a YAML document
+	if err := yaml.Unmarshal(content, &definition); err != nil {
+		return nil, fmt.Errorf("unable to parse OpenAPI definition: %s", err.Error())
+	}
+
+	if definition.Host != "" {
+		hosts = append(hosts, definition.Host)
+	}
+	for _, server := range definition.Servers {
+		serverURL := server.URL
+		for name, variable := range server.Variables {
+			serverURL = strings.ReplaceAll(serverURL, "{"+name+"}", variable.Default)
+		}
+		if u, err := url.Parse(serverURL); err == nil && u.Host != "" {
+			hosts = append(hosts, u.Host)
+		}
+	}
+	return hosts, nil
+}
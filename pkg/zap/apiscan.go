@@ -0,0 +1,168 @@
+package zap
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// The current ZAP release (2.11.1) requires some of the file path args to be given relative to the /zap/wrk/ dir.
+// Of the arguments that zap-api-scan uses, this includes the context file (-n), config file (-c), and report output
+// file (-x). Future releases of ZAP will not have this limitation and will allow fully qualified paths, including
+// paths outside of the /zap/wrk/ dir.
+const (
+	ApiScanReportFileName      = "report.xml"
+	ApiScanContextFileName     = "context.xml"
+	ApiScanAuthScriptFileName  = "authScript" // corresponds to the file name in auth_script_hook.py
+	ApiScanConfigFileName      = "config.txt"
+	ApiScanAuthHookFileName    = "auth_script_hook.py"
+	ApiScanOAuth2TokenFileName = "oauth2Token" // the file that auth_script_hook.py reads for the OAuth2 header value
+)
+
+// ApiScanFile describes a file in the ZAP working directory that an API scan uses.
+type ApiScanFile struct {
+	Path        string `json:"path"`
+	Description string `json:"description"`
+}
+
+// ApiScanArguments returns the zap-api-scan.py command-line arguments, beginning with the script path, for the
+// single context of an API scan.
+func ApiScanArguments(config *Config, zapApiScanPath string, zapWorkDir string) []string {
+
+	contextConfig := config.GetContexts()[0]
+
+	args := []string{
+		zapApiScanPath,
+		"-t", contextConfig.Context.Target,
+		"-f", contextConfig.Context.Format,
+		"-x", ApiScanReportFileName,
+	}
+
+	if contextConfig.usesApiScanAuthHook() {
+		// the hook loads the authScript or sets the OAuth2 header in the api-scan ZAP daemon after it starts
+		args = append(args, "--hook", filepath.Join(zapWorkDir, ApiScanAuthHookFileName))
+	}
+
+	if contextConfig.IsContextFileRequired() {
+		if contextConfig.IsContextAuthRequired() {
+			args = append(args, "-U", contextConfig.GetCredentials()[0].Username)
+		}
+		args = append(args, "-n", ApiScanContextFileName)
+	}
+
+	if contextConfig.Context.OpenApiHostnameOverride != "" {
+		args = append(args, "-O", contextConfig.Context.OpenApiHostnameOverride)
+	}
+
+	if !config.ScanOptions.RunActiveScan {
+		args = append(args, "-S")
+	}
+
+	if zapOptions := config.apiScanZapOptions(); len(zapOptions) > 0 {
+		args = append(args, "-z", strings.Join(zapOptions, " "))
+	}
+
+	if config.ScanOptions.ApiScanConfigContent != "" {
+		args = append(args, "-c", ApiScanConfigFileName)
+	}
+
+	return append(args, config.ScanOptions.ApiScanOptions...)
+}
+
+// usesApiScanAuthHook reports whether an API scan runs auth_script_hook.py, which loads the authentication script
+// for script authentication and keeps the refreshed token in the authentication header for OAuth2 authentication.
+func (c *ContextConfig) usesApiScanAuthHook() bool {
+	return (c.IsContextAuthRequired() && c.UseScriptAuthentication()) || c.useApiScanOAuth2TokenFile()
+}
+
+// useApiScanOAuth2TokenFile reports whether an API scan delivers OAuth2 tokens through the file that
+// auth_script_hook.py reads.
+func (c *ContextConfig) useApiScanOAuth2TokenFile() bool {
+	return c.IsAuthenticationEnabled() && c.UseOAuth2Authentication()
+}
+
+// apiScanZapOptions returns the ZAP command-line options, which zap-api-scan accepts as a single -z argument, that
+// limit the scan to the scope allowlist and set the ZAP mode.
+func (c *Config) apiScanZapOptions() []string {
+
+	zapOptions := c.Scope.ZapConfigOptions()
+	if c.ScanOptions.Mode != "" {
+		zapOptions = append(zapOptions, "-config", "view.mode="+c.ScanOptions.Mode)
+	}
+	return zapOptions
+}
+
+// ApiScanFiles returns the files that an API scan writes to the ZAP working directory before zap-api-scan runs,
+// followed by the report that zap-api-scan writes.
+func ApiScanFiles(config *Config, zapWorkDir string) []ApiScanFile {
+
+	contextConfig := config.GetContexts()[0]
+
+	files := make([]ApiScanFile, 0)
+	if contextConfig.IsContextFileRequired() {
+		description := "the context exported from a temporary ZAP instance"
+		if !contextConfig.IsContextExportRequired() {
+			description = "the context from context.contextFile"
+		} else if contextConfig.UseContextFile() {
+			description = "the context from context.contextFile, with the credentials and settings applied, exported from a temporary ZAP instance"
+		}
+		files = append(files, ApiScanFile{Path: filepath.Join(zapWorkDir, ApiScanContextFileName), Description: description})
+
+		if contextConfig.IsContextAuthRequired() && contextConfig.UseScriptAuthentication() {
+			files = append(files, ApiScanFile{Path: filepath.Join(zapWorkDir, ApiScanAuthScriptFileName), Description: "the authentication script loaded by auth_script_hook.py"})
+		}
+	}
+
+	if contextConfig.useApiScanOAuth2TokenFile() {
+		files = append(files, ApiScanFile{Path: filepath.Join(zapWorkDir, ApiScanOAuth2TokenFileName), Description: "the OAuth2 authentication header value, refreshed during the scan and read by auth_script_hook.py"})
+	}
+
+	if config.ScanOptions.ApiScanConfigContent != "" {
+		files = append(files, ApiScanFile{Path: filepath.Join(zapWorkDir, ApiScanConfigFileName), Description: "the API scan rule config from scanOptions.apiScanConfigContent"})
+	}
+
+	return append(files, ApiScanFile{Path: filepath.Join(zapWorkDir, ApiScanReportFileName), Description: "the report written by zap-api-scan"})
+}
+
+// ApiScanEnvironment returns the environment variables that zap-api-scan and auth_script_hook.py read for the
+// context's authentication, using the specified header authentication value. An OAuth2 token, which expires during
+// long scans, reaches the hook through a file in the ZAP working directory instead.
+func ApiScanEnvironment(config *ContextConfig, authHeaderValue string, zapWorkDir string) []string {
+
+	env := make([]string, 0)
+	if config.IsContextAuthRequired() && config.UseScriptAuthentication() {
+		// auth_script_hook.py loads the authScript using this script engine
+		env = append(env, fmt.Sprintf("ZAP_AUTH_SCRIPT_ENGINE=%s", config.ScriptAuthentication.AuthenticationScriptEngine))
+	}
+
+	if !config.IsAuthenticationEnabled() {
+		return env
+	}
+
+	if config.useApiScanOAuth2TokenFile() {
+		name := config.OAuth2Authentication.AuthHeaderName
+		if name == "" {
+			name = "Authorization"
+		}
+		env = append(env,
+			fmt.Sprintf("ZAP_OAUTH2_TOKEN_FILE=%s", filepath.Join(zapWorkDir, ApiScanOAuth2TokenFileName)),
+			fmt.Sprintf("ZAP_OAUTH2_HEADER=%s", name))
+		if urlRegex := authenticationHeaderURLRegex(config.OAuth2Authentication.AuthHeaderSite); urlRegex != "" {
+			env = append(env, fmt.Sprintf("ZAP_OAUTH2_HEADER_URL=%s", urlRegex))
+		}
+		return env
+	}
+
+	if !config.UseHeaderAuthentication() {
+		return env
+	}
+
+	env = append(env, fmt.Sprintf("ZAP_AUTH_HEADER_VALUE=%s", authHeaderValue))
+	if name := config.HeaderAuthentication.AuthHeaderName; name != "" {
+		env = append(env, fmt.Sprintf("ZAP_AUTH_HEADER=%s", name))
+	}
+	if site := config.HeaderAuthentication.AuthHeaderSite; site != "" {
+		env = append(env, fmt.Sprintf("ZAP_AUTH_HEADER_SITE=%s", site))
+	}
+	return env
+}
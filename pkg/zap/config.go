@@ -1,31 +1,63 @@
 package zap
 
 import (
+	"bytes"
 	"errors"
-	"github.com/spf13/viper"
+	"fmt"
+	"io"
 	"io/ioutil"
-	"os"
-	"path"
+	"log"
 	"path/filepath"
 	"strings"
+
+	"github.com/spf13/viper"
 )
 
 type request struct {
 	Name           string
 	SecretsToMount []string
 	WorkDirectory  string
+	Reserved       map[string]interface{} `mapstructure:",remain"` // other request keys, such as shellCmd, used by Code Dx
 }
 
 func (r *request) GetWorkflowSecretsDirectory() string {
 	return filepath.ToSlash(filepath.Join(r.WorkDirectory, "workflow-secrets"))
 }
 
+func (r *request) GetInputDirectory() string {
+	return filepath.ToSlash(filepath.Join(r.WorkDirectory, "input"))
+}
+
+// resolveInputPath returns the path of a file that is either absolute or relative to the analysis input directory.
+func (r *request) resolveInputPath(filePath string) string {
+	if filepath.IsAbs(filePath) {
+		return filePath
+	}
+	return filepath.Join(filepath.FromSlash(r.GetInputDirectory()), filePath)
+}
+
 type context struct {
+	Name                                  string
+	Target                                string
+	Targets                               []target // normal scan only; the targets scanned in turn instead of target
+	Format                                string   // api scan only
+	OpenApiHostnameOverride               string   // api scan only
+	ImportURLs                            []string // normal scan only
+	IncludeRegularExpressions             []string
+	ExcludeRegularExpressions             []string
+	AntiCrossSiteRequestForgeryTokenNames []string
+	IncludeTechnologies                   []string // ZAP technology names, such as Db.PostgreSQL or Language.Java
+	ExcludeTechnologies                   []string
+	StructuralParameters                  []string // names of URL parameters that identify distinct pages, such as page in /index.php?page=view
+	DataDrivenNodes                       []string // regular expressions with a second group matching a data-driven part of the URL path
+	ContextFile                           string   // inline ZAP context file content or a path relative to the analysis input directory
+}
+
+// target is a URL that a context's targets list scans with the context's settings. The include and exclude regular
+// expressions, when specified, replace the context's.
+type target struct {
 	Name                      string
-	Target                    string
-	Format                    string // api scan only
-	OpenApiHostnameOverride   string // api scan only
-	ImportURLs                []string // normal scan only
+	URL                       string
 	IncludeRegularExpressions []string
 	ExcludeRegularExpressions []string
 }
@@ -37,14 +69,26 @@ type reportOptions struct {
 
 type scanOptions struct {
 	RunActiveScan        bool
+	Mode                 string   // either safe (passive API scan only), protected, or standard
 	ApiScanOptions       []string // api scan only
-	ApiScanConfigContent string // api scan only
+	ApiScanConfigContent string   // api scan only
+}
+
+// zapModes lists the ZAP modes that scanOptions.mode accepts. Safe mode forbids the active scan and the spider that
+// every normal scan runs, so only passive API scans accept it.
+var zapModes = map[string]bool{
+	"safe":      true,
+	"protected": true,
+	"standard":  true,
 }
 
 type authentication struct {
-	Type                string
-	LoginIndicatorRegex string
-	ForcedUserMode      bool // normal scan only
+	Type                     string
+	LoginIndicatorRegex      string
+	LoggedOutIndicatorRegex  string
+	ForcedUserMode           bool     // normal scan only
+	ExcludeLogoutLinks       bool     // normal scan only
+	LogoutRegularExpressions []string // normal scan only
 }
 
 // Credentials contains the usernames/passwords to use for spiders/scans.
@@ -53,88 +97,238 @@ type Credentials []Credential
 
 // Credential contains a user credential to use for a spider/scan.
 type Credential struct {
-	Username string
-	Password string
+	Username     string
+	Password     string
+	RefreshToken string // oauth2Authentication only
+	TotpSecret   string // normal scan only - api scans reject credentials with a TOTP secret
+	Metadata     UserMetadata
 }
 
 type formAuthentication struct {
-	FormURL                                  string
-	FormUsernameFieldName                    string
-	FormPasswordFieldName                    string
-	FormAntiCrossSiteRequestForgeryFieldName string
-	FormExtraPostData                        string
+	FormURL                                   string
+	FormUsernameFieldName                     string
+	FormPasswordFieldName                     string
+	FormAntiCrossSiteRequestForgeryFieldNames []string
+	DiscoverAntiCrossSiteRequestForgeryFields bool
+	FormPageURL                               string
+	FormExtraPostData                         string
+}
+
+// antiCrossSiteRequestForgeryFieldNames returns the configured login form anti-CSRF field names.
+func (f *formAuthentication) antiCrossSiteRequestForgeryFieldNames() []string {
+	return appendUnique(make([]string, 0), f.FormAntiCrossSiteRequestForgeryFieldNames...)
+}
+
+// formPageURL returns the URL of the page containing the login form.
+func (f *formAuthentication) formPageURL() string {
+	if f.FormPageURL != "" {
+		return f.FormPageURL
+	}
+	return f.FormURL
 }
 
 type scriptAuthentication struct {
-	AuthenticationScriptContent string
+	AuthenticationScriptContent    string
+	AuthenticationScriptFile       string
+	AuthenticationScriptEngine     string
+	AuthenticationScriptParameters []scriptParameter
+	UsernameParameterName          string
+	PasswordParameterName          string
+}
+
+type scriptParameter struct {
+	Name  string
+	Value string
+}
+
+// scriptEngineAliases maps friendly script engine names to ZAP script engine names.
+var scriptEngineAliases = map[string]string{
+	"zest":       "Mozilla Zest",
+	"graaljs":    "Graal.js",
+	"graal.js":   "Graal.js",
+	"javascript": "Graal.js",
+	"js":         "Graal.js",
+	"ecmascript": "Graal.js",
+	"python":     "jython",
+	"jython":     "jython",
 }
 
-// api scan only
 type headerAuthentication struct {
 	AuthHeaderName string
 	AuthHeaderSite string
 }
 
+type oauth2Authentication struct {
+	TokenURL             string
+	Scope                string
+	AuthHeaderName       string
+	AuthHeaderSite       string
+	RefreshMarginSeconds int // normal scan only
+}
+
+// ContextConfig holds the configuration of a ZAP context, its authentication approach, and its users.
+type ContextConfig struct {
+	Context                       context
+	Authentication                authentication
+	FormAuthentication            formAuthentication
+	ScriptAuthentication          scriptAuthentication
+	HeaderAuthentication          headerAuthentication
+	OAuth2Authentication          oauth2Authentication
+	AlertFilters                  []alertFilter
+	WorkflowSecrets               []string // the workflow secrets holding this context's credentials; all secrets when empty
+	CredentialSources             credentialSources
+	credentials                   Credentials // reading credentials from TOML file is unsupported - use SecretsToMount instead
+	contextFileContent            string
+	contextFileLoggedOutIndicator string
+	index                         int
+	isTarget                      bool              // the context scans an entry of a targets list
+	expansion                     *contextExpansion // the request file settings of a context that expandTargets created
+	redactor                      *Redactor
+}
+
 // Config holds the configuration describing how to run the ZAP tool.
 type Config struct {
-	Request              request
-	Context              context
-	ReportOptions        reportOptions
-	ScanOptions          scanOptions
-	Authentication       authentication
-	FormAuthentication   formAuthentication
-	ScriptAuthentication scriptAuthentication
-	HeaderAuthentication headerAuthentication // api scan only
-	credentials          Credentials // reading credentials from TOML file is unsupported - use SecretsToMount instead
-}
-
-func (c *Config) UseFormAuthentication() bool {
+	SchemaVersion    int      // the request file layout version; see CurrentSchemaVersion
+	Extends          string   // a base request file, absolute or relative to the analysis input directory, that the file overlays
+	ReplaceBaseLists []string // the key paths, such as context.excludeRegularExpressions, of lists replacing the base's instead of combining with them
+	Request          request
+	ContextConfig    `mapstructure:",squash"`
+	Contexts         []ContextConfig // normal scan only when specifying more than one context
+	ReportOptions    reportOptions
+	ScanOptions      scanOptions
+	Scope            scope
+
+	activeScanAllowlist *TargetAllowlist // operator-controlled, so it cannot be set by the request file
+	redactor            *Redactor
+}
+
+func (c *ContextConfig) UseFormAuthentication() bool {
 	return c.Authentication.Type == "formAuthentication"
 }
 
-func (c *Config) UseScriptAuthentication() bool {
+func (c *ContextConfig) UseScriptAuthentication() bool {
 	return c.Authentication.Type == "scriptAuthentication"
 }
 
-func (c *Config) UseHeaderAuthentication() bool {
+func (c *ContextConfig) UseHeaderAuthentication() bool {
 	return c.Authentication.Type == "headerAuthentication"
 }
 
-func (c *Config) IsAuthenticationEnabled() bool {
-	return (c.UseFormAuthentication() || c.UseScriptAuthentication() || c.UseHeaderAuthentication()) && c.credentials != nil && len(c.credentials) > 0
+func (c *ContextConfig) UseOAuth2Authentication() bool {
+	return c.Authentication.Type == "oauth2Authentication"
+}
+
+func (c *ContextConfig) IsAuthenticationEnabled() bool {
+	return (c.UseFormAuthentication() || c.UseScriptAuthentication() || c.UseHeaderAuthentication() || c.UseOAuth2Authentication()) && c.credentials != nil && len(c.credentials) > 0
 }
 
-func (c *Config) IsContextAuthRequired() bool {
+func (c *ContextConfig) IsContextAuthRequired() bool {
 	return c.IsAuthenticationEnabled() && (c.UseScriptAuthentication() || c.UseFormAuthentication())
 }
 
-func (c *Config) IsContextFileRequired() bool {
-	return len(c.Context.IncludeRegularExpressions) > 0 || len(c.Context.ExcludeRegularExpressions) > 0 || c.IsContextAuthRequired()
+func (c *ContextConfig) IsContextFileRequired() bool {
+	return c.UseContextFile() || len(c.Context.IncludeRegularExpressions) > 0 || len(c.Context.ExcludeRegularExpressions) > 0 || c.IsContextAuthRequired() || c.hasContextSettings()
 }
 
-func (c *Config) IsValid(scanMode string) bool {
-	if c.Context.Name == "" || c.Context.Target == "" {
-		return false
-	}
-	if IsNormalScan(scanMode) {
-		// disallow api-scan only fields
-		return c.Context.Format == "" &&
-			c.Context.OpenApiHostnameOverride == "" &&
-			len(c.ScanOptions.ApiScanOptions) == 0 &&
-			c.ScanOptions.ApiScanConfigContent == "" &&
-			!c.UseHeaderAuthentication()
-	} else if IsApiScan(scanMode) {
-		// require format be defined and disallow normal-scan only fields
-		return c.Context.Format != "" && !c.Authentication.ForcedUserMode && len(c.Context.ImportURLs) == 0
-	}
-	return false
+// hasContextSettings reports whether the context has settings, such as technologies, alert filters, and structure,
+// that only a ZAP context carries, so that an API scan must be given the context.
+func (c *ContextConfig) hasContextSettings() bool {
+	return len(c.Context.IncludeTechnologies) > 0 || len(c.Context.ExcludeTechnologies) > 0 || len(c.AlertFilters) > 0 || c.HasContextStructure()
 }
 
 // GetCredentials returns a list of ZAP user credentials loaded via a scan request file.
-func (c *Config) GetCredentials() Credentials {
+func (c *ContextConfig) GetCredentials() Credentials {
 	return c.credentials
 }
 
+// resourceName returns a name for a ZAP resource, such as a script or replacer rule, that is unique to the context.
+func (c *ContextConfig) resourceName(name string) string {
+	if c.index == 0 {
+		return name
+	}
+	return fmt.Sprintf("%s-%d", name, c.index+1)
+}
+
+// GetContexts returns the configuration of each ZAP context, which is either the single context configured by the
+// top-level sections or the list of contexts configured by the contexts array.
+func (c *Config) GetContexts() []*ContextConfig {
+	if len(c.Contexts) == 0 {
+		return []*ContextConfig{&c.ContextConfig}
+	}
+	contexts := make([]*ContextConfig, len(c.Contexts))
+	for i := range c.Contexts {
+		contexts[i] = &c.Contexts[i]
+	}
+	return contexts
+}
+
+// SetActiveScanAllowlist limits active scans to targets on the specified allowlist.
+func (c *Config) SetActiveScanAllowlist(allowlist *TargetAllowlist) {
+	c.activeScanAllowlist = allowlist
+}
+
+// CheckActiveScanTargets returns an error when an active scan would target a host that is not on the active scan
+// allowlist. Passive-only scans and scans without an allowlist are unrestricted.
+func (c *Config) CheckActiveScanTargets() error {
+	for _, ctx := range c.GetContexts() {
+		if err := c.checkActiveScanTarget(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Config) checkActiveScanTarget(ctx *ContextConfig) error {
+
+	if c.activeScanAllowlist == nil || !c.ScanOptions.RunActiveScan {
+		return nil
+	}
+
+	if err := c.activeScanAllowlist.CheckTarget(ctx.Context.Target); err != nil {
+		return err
+	}
+	if ctx.Context.OpenApiHostnameOverride != "" {
+		if err := c.activeScanAllowlist.CheckHost(ctx.Context.OpenApiHostnameOverride); err != nil {
+			return err
+		}
+	}
+
+	// each user's start URLs are actively scanned in place of the target
+	for _, cred := range ctx.GetCredentials() {
+		for _, startURL := range cred.Metadata.StartURLs {
+			if err := c.activeScanAllowlist.CheckTarget(startURL); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// CheckApiDefinitionActiveScanTargets returns an error when an active API scan of an API definition file would
+// target a server host that is not on the active scan allowlist or when the definition specifies no server host,
+// which zap-api-scan reads relative to the ZAP working directory. Targets that are URLs are checked by
+// CheckActiveScanTargets.
+func (c *Config) CheckApiDefinitionActiveScanTargets(ctx *ContextConfig, zapWorkDir string) error {
+
+	if c.activeScanAllowlist == nil || !c.ScanOptions.RunActiveScan || !ctx.isApiDefinitionFile() || ctx.Context.OpenApiHostnameOverride != "" {
+		return nil
+	}
+
+	hosts, err := ApiDefinitionHosts(ctx, zapWorkDir)
+	if err != nil {
+		return err
+	}
+	if len(hosts) == 0 {
+		return fmt.Errorf("API definition %s specifies no server host to check against the target allowlist; specify context.openApiHostnameOverride", ctx.Context.Target)
+	}
+	for _, host := range hosts {
+		if err := c.activeScanAllowlist.CheckHost(host); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func IsApiScan(scanMode string) bool {
 	return scanMode == "api"
 }
@@ -143,91 +337,201 @@ func IsNormalScan(scanMode string) bool {
 	return scanMode == "normal"
 }
 
-// ParseConfig reads configuration data from a request file in either the current directory or the config subdirectory.
-func ParseConfig(configFilePath string, scanMode string) (*Config, error) {
+// configFormats maps request file extensions to configuration formats.
+var configFormats = map[string]string{
+	".toml": "toml",
+	".json": "json",
+	".yaml": "yaml",
+	".yml":  "yaml",
+}
 
-	dir, filename := filepath.Split(configFilePath)
-	extension := filepath.Ext(filename)
-	filenameNoExtension := filename[0 : len(filename)-len(extension)]
+// ParseConfig reads configuration data from the request file at the specified path. The file extension determines
+// whether the file contains TOML, JSON, or YAML, and the content determines the format for other extensions. A
+// request file with an extends key overlays the base request file it names. The overrides replace request file values,
+// after merging, in the order specified.
+func ParseConfig(configFilePath string, scanMode string, overrides ...ConfigOverride) (*Config, error) {
 
-	var cfg Config
-	viper.SetConfigName(filenameNoExtension)
-	viper.AddConfigPath(dir)
-	err := viper.ReadInConfig()
+	content, err := ioutil.ReadFile(configFilePath)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseConfig(content, ConfigFormat(configFilePath, content), scanMode, overrides)
+}
+
+// ConfigFormat returns the format of request file content, either toml, json, or yaml, based on the file extension
+// or, for other extensions, the content.
+func ConfigFormat(configFilePath string, content []byte) string {
+	if format, ok := configFormats[strings.ToLower(filepath.Ext(configFilePath))]; ok {
+		return format
+	}
+	return detectConfigFormat(content)
+}
+
+// ParseConfigReader reads TOML, JSON, or YAML configuration data from a reader. The overrides replace values in the
+// order specified.
+func ParseConfigReader(r io.Reader, scanMode string, overrides ...ConfigOverride) (*Config, error) {
+
+	content, err := ioutil.ReadAll(r)
 	if err != nil {
 		return nil, err
 	}
-	if err = viper.Unmarshal(&cfg); err != nil {
+	return parseConfig(content, detectConfigFormat(content), scanMode, overrides)
+}
+
+// detectConfigFormat returns json for content that is a JSON object, toml for content that TOML can parse, and
+// yaml otherwise.
+func detectConfigFormat(content []byte) string {
+
+	if strings.HasPrefix(strings.TrimSpace(string(content)), "{") {
+		return "json"
+	}
+
+	v := viper.New()
+	v.SetConfigType("toml")
+	if err := v.ReadConfig(bytes.NewReader(content)); err != nil {
+		return "yaml"
+	}
+	return "toml"
+}
+
+func parseConfig(content []byte, format string, scanMode string, overrides []ConfigOverride) (*Config, error) {
+
+	settings, err := readSettings(content, format)
+	if err != nil {
 		return nil, err
 	}
 
-	applyDefaults(&cfg, scanMode)
+	settings, err = extendSettings(settings, settingsRequest(settings), make(map[string]bool))
+	if err != nil {
+		return nil, err
+	}
+
+	v := viper.New()
+	if err := v.MergeConfigMap(settings); err != nil {
+		return nil, err
+	}
+
+	for _, override := range overrides {
+		v.Set(override.Key, override.value())
+	}
+
+	// unknown keys, such as a misspelled option, are errors instead of being silently ignored
+	var cfg Config
+	if err := v.UnmarshalExact(&cfg); err != nil {
+		return nil, fmt.Errorf("unable to read request file: %s", err.Error())
+	}
 
-	if err := loadCredentials(&cfg, scanMode); err != nil {
+	if err := expandTargets(&cfg, scanMode); err != nil {
 		return nil, err
 	}
 
+	applyDefaults(&cfg, scanMode)
+
+	for _, ctx := range cfg.GetContexts() {
+		if err := loadContextFile(ctx, &cfg.Request); err != nil {
+			return nil, err
+		}
+
+		if err := loadAuthenticationScript(ctx, &cfg.Request); err != nil {
+			return nil, err
+		}
+
+		if err := loadCredentials(ctx, &cfg.Request, scanMode); err != nil {
+			return nil, err
+		}
+	}
+
+	// learn the loaded secrets so that they can be redacted
+	cfg.Redactor()
+
 	return &cfg, nil
 }
 
+// readSettings reads the settings of TOML, JSON, or YAML request file content, upgrading an older request file
+// layout in memory.
+func readSettings(content []byte, format string) (map[string]interface{}, error) {
+
+	fileConfig := viper.New()
+	fileConfig.SetConfigType(format)
+	if err := fileConfig.ReadConfig(bytes.NewReader(content)); err != nil {
+		return nil, fmt.Errorf("unable to read %s request file: %s", strings.ToUpper(format), err.Error())
+	}
+
+	settings := fileConfig.AllSettings()
+	normalizeSettings(settings)
+	warnings, err := migrateSettings(settings)
+	if err != nil {
+		return nil, err
+	}
+	for _, warning := range warnings {
+		log.Printf("Warning: %s", warning)
+	}
+	return settings, nil
+}
+
 func applyDefaults(config *Config, scanMode string) {
 
+	for i, ctx := range config.GetContexts() {
+		ctx.index = i
+		applyContextDefaults(ctx, scanMode)
+	}
+}
+
+func applyContextDefaults(config *ContextConfig, scanMode string) {
+
 	if config.Context.Name == "" {
 		config.Context.Name = "Context"
+		if config.index > 0 {
+			config.Context.Name = fmt.Sprintf("Context%d", config.index+1)
+		}
 	}
 
-	if len(config.Context.IncludeRegularExpressions) == 0 && IsNormalScan(scanMode) {
+	// a context file includes its own regular expressions
+	if len(config.Context.IncludeRegularExpressions) == 0 && IsNormalScan(scanMode) && !config.UseContextFile() {
 		config.Context.IncludeRegularExpressions = append(config.Context.IncludeRegularExpressions, config.Context.Target+".*")
 	}
-}
 
-func loadCredentials(config *Config, scanMode string) error {
-
-	credentialsDirectory := config.Request.GetWorkflowSecretsDirectory()
-	if credentialsDirectory == "" {
-		return nil
+	// the settings of an API scan's context apply only to URLs in the context, which the API definition lists
+	if len(config.Context.IncludeRegularExpressions) == 0 && IsApiScan(scanMode) && !config.UseContextFile() && config.hasContextSettings() {
+		config.Context.IncludeRegularExpressions = []string{".*"}
 	}
 
-	if config.credentials == nil {
-		config.credentials = make([]Credential, 0)
+	if config.Authentication.ExcludeLogoutLinks && len(config.Authentication.LogoutRegularExpressions) == 0 {
+		config.Authentication.LogoutRegularExpressions = defaultLogoutRegularExpressions
 	}
 
-	return filepath.Walk(credentialsDirectory, func(filePath string, info os.FileInfo, e error) error {
+	scriptAuth := &config.ScriptAuthentication
+	if scriptAuth.AuthenticationScriptEngine == "" {
+		scriptAuth.AuthenticationScriptEngine = "Mozilla Zest"
+	} else if engine, ok := scriptEngineAliases[strings.ToLower(scriptAuth.AuthenticationScriptEngine)]; ok {
+		scriptAuth.AuthenticationScriptEngine = engine
+	}
+	if scriptAuth.UsernameParameterName == "" {
+		scriptAuth.UsernameParameterName = "Username"
+	}
+	if scriptAuth.PasswordParameterName == "" {
+		scriptAuth.PasswordParameterName = "Password"
+	}
+}
 
-		if filePath == credentialsDirectory || !info.IsDir() {
-			return nil
-		}
+// loadAuthenticationScript reads the authentication script from a file in the analysis input directory when
+// authenticationScriptFile is specified.
+func loadAuthenticationScript(config *ContextConfig, request *request) error {
 
-		if IsApiScan(scanMode) && len(config.credentials) > 0 {
-			return errors.New("only one credential can be defined")
-		}
+	scriptAuth := &config.ScriptAuthentication
+	if scriptAuth.AuthenticationScriptFile == "" {
+		return nil
+	}
 
-		user := ""
-		pass := ""
+	if scriptAuth.AuthenticationScriptContent != "" {
+		return errors.New("specify either authenticationScriptContent or authenticationScriptFile, not both")
+	}
 
-		if config.UseHeaderAuthentication() {
-			var err error
-			p, err := ioutil.ReadFile(filepath.FromSlash(path.Join(filePath, "header-value")))
-			if err != nil {
-				return err
-			}
-			pass = string(p)
-		} else {
-			u, err := ioutil.ReadFile(filepath.FromSlash(path.Join(filePath, "username")))
-			if err != nil {
-				return err
-			}
-			p, err := ioutil.ReadFile(filepath.FromSlash(path.Join(filePath, "password")))
-			if err != nil {
-				return err
-			}
-			user = string(u)
-			pass = string(p)
-		}
-		config.credentials = append(config.credentials, Credential{
-			Username: strings.TrimSpace(user),
-			Password: strings.TrimSpace(pass),
-		})
-		return filepath.SkipDir
-	})
+	b, err := ioutil.ReadFile(request.resolveInputPath(scriptAuth.AuthenticationScriptFile))
+	if err != nil {
+		return err
+	}
+	scriptAuth.AuthenticationScriptContent = string(b)
+	return nil
 }
@@ -0,0 +1,394 @@
+package zap
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/codedx/codedx-add-ins/pkg/assert"
+)
+
+func writeSecret(t *testing.T, workDirectory string, user string, name string, value string) {
+	dir := filepath.Join(workDirectory, "workflow-secrets", user)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(value), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestIsValidAllowsHeaderAuthenticationForNormalScan(t *testing.T) {
+
+	cfg := Config{}
+	cfg.Context.Name = "Context"
+	cfg.Context.Target = "http://localhost"
+	cfg.Authentication.Type = "headerAuthentication"
+
+	assert.True(t, cfg.IsValid("normal"))
+}
+
+func TestLoadCredentialsReadsHeaderValue(t *testing.T) {
+
+	workDirectory := t.TempDir()
+	writeSecret(t, workDirectory, "token", "header-value", "Bearer abc\n")
+
+	cfg := Config{}
+	cfg.Request.WorkDirectory = workDirectory
+	cfg.Authentication.Type = "headerAuthentication"
+
+	assert.NilError(t, loadCredentials(&cfg.ContextConfig, &cfg.Request, "normal"))
+	assert.IntsAreEqual(t, 1, len(cfg.GetCredentials()))
+	assert.StringsAreEqual(t, "Bearer abc", cfg.GetCredentials()[0].Password)
+	assert.True(t, cfg.IsAuthenticationEnabled())
+	assert.False(t, cfg.IsContextAuthRequired())
+}
+
+func TestLoadCredentialsAllowsOneHeaderValueForNormalScan(t *testing.T) {
+
+	workDirectory := t.TempDir()
+	writeSecret(t, workDirectory, "token1", "header-value", "one")
+	writeSecret(t, workDirectory, "token2", "header-value", "two")
+
+	cfg := Config{}
+	cfg.Request.WorkDirectory = workDirectory
+	cfg.Authentication.Type = "headerAuthentication"
+
+	assert.NotNil(t, loadCredentials(&cfg.ContextConfig, &cfg.Request, "normal"))
+}
+
+func TestLoadCredentialsReadsOAuth2ClientCredentials(t *testing.T) {
+
+	workDirectory := t.TempDir()
+	writeSecret(t, workDirectory, "client", "client-id", "id")
+	writeSecret(t, workDirectory, "client", "client-secret", "secret\n")
+
+	cfg := Config{}
+	cfg.Request.WorkDirectory = workDirectory
+	cfg.Authentication.Type = "oauth2Authentication"
+
+	assert.NilError(t, loadCredentials(&cfg.ContextConfig, &cfg.Request, "normal"))
+	assert.StringsAreEqual(t, "id", cfg.GetCredentials()[0].Username)
+	assert.StringsAreEqual(t, "secret", cfg.GetCredentials()[0].Password)
+	assert.EmptyString(t, cfg.GetCredentials()[0].RefreshToken)
+}
+
+func TestLoadCredentialsRequiresOAuth2Secret(t *testing.T) {
+
+	workDirectory := t.TempDir()
+	writeSecret(t, workDirectory, "client", "client-id", "id")
+
+	cfg := Config{}
+	cfg.Request.WorkDirectory = workDirectory
+	cfg.Authentication.Type = "oauth2Authentication"
+
+	assert.NotNil(t, loadCredentials(&cfg.ContextConfig, &cfg.Request, "normal"))
+}
+
+func TestLoadCredentialsReadsTOTPSecret(t *testing.T) {
+
+	workDirectory := t.TempDir()
+	writeSecret(t, workDirectory, "user", "username", "user")
+	writeSecret(t, workDirectory, "user", "password", "pass")
+	writeSecret(t, workDirectory, "user", "totp-secret", rfc6238Secret+"\n")
+
+	cfg := Config{}
+	cfg.Request.WorkDirectory = workDirectory
+	cfg.Authentication.Type = "formAuthentication"
+
+	assert.NilError(t, loadCredentials(&cfg.ContextConfig, &cfg.Request, "normal"))
+	assert.StringsAreEqual(t, rfc6238Secret, cfg.GetCredentials()[0].TotpSecret)
+
+	cfg.credentials = nil
+	err := loadCredentials(&cfg.ContextConfig, &cfg.Request, "api")
+	assert.NotNil(t, err)
+	assert.True(t, strings.Contains(err.Error(), "api scans do not support"))
+}
+
+func TestApplyDefaultsScriptEngine(t *testing.T) {
+
+	cfg := Config{}
+	applyDefaults(&cfg, "normal")
+	assert.StringsAreEqual(t, "Mozilla Zest", cfg.ScriptAuthentication.AuthenticationScriptEngine)
+	assert.StringsAreEqual(t, "Username", cfg.ScriptAuthentication.UsernameParameterName)
+	assert.StringsAreEqual(t, "Password", cfg.ScriptAuthentication.PasswordParameterName)
+
+	cfg.ScriptAuthentication.AuthenticationScriptEngine = "GraalJS"
+	applyDefaults(&cfg, "normal")
+	assert.StringsAreEqual(t, "Graal.js", cfg.ScriptAuthentication.AuthenticationScriptEngine)
+
+	cfg.ScriptAuthentication.AuthenticationScriptEngine = "Python"
+	applyDefaults(&cfg, "normal")
+	assert.StringsAreEqual(t, "jython", cfg.ScriptAuthentication.AuthenticationScriptEngine)
+}
+
+func TestLoadAuthenticationScriptFromInputDirectory(t *testing.T) {
+
+	workDirectory := t.TempDir()
+	scriptDirectory := filepath.Join(workDirectory, "input", "scripts")
+	if err := os.MkdirAll(scriptDirectory, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(scriptDirectory, "auth.js"), []byte("function authenticate() {}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := Config{}
+	cfg.Request.WorkDirectory = workDirectory
+	cfg.ScriptAuthentication.AuthenticationScriptFile = "scripts/auth.js"
+
+	assert.NilError(t, loadAuthenticationScript(&cfg.ContextConfig, &cfg.Request))
+	assert.StringsAreEqual(t, "function authenticate() {}", cfg.ScriptAuthentication.AuthenticationScriptContent)
+
+	assert.NotNil(t, loadAuthenticationScript(&cfg.ContextConfig, &cfg.Request))
+}
+
+func writeRequestFile(t *testing.T, content string) string {
+	requestFile := filepath.Join(t.TempDir(), "request.toml")
+	if err := os.WriteFile(requestFile, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return requestFile
+}
+
+func TestParseConfigWithContexts(t *testing.T) {
+
+	workDirectory := t.TempDir()
+	writeSecret(t, workDirectory, "admin", "username", "admin")
+	writeSecret(t, workDirectory, "admin", "password", "pass1")
+	writeSecret(t, workDirectory, "reader", "username", "reader")
+	writeSecret(t, workDirectory, "reader", "password", "pass2")
+
+	requestFile := writeRequestFile(t, `
+[[contexts]]
+[contexts.context]
+target = "http://localhost/"
+excludeRegularExpressions = ["http://localhost/admin.*"]
+
+[[contexts]]
+workflowSecrets = ["admin"]
+[contexts.context]
+name = "Admin"
+target = "http://localhost/admin"
+[contexts.authentication]
+type = "formAuthentication"
+[contexts.formAuthentication]
+formURL = "http://localhost/login"
+formUsernameFieldName = "username"
+formPasswordFieldName = "password"
+
+[scanOptions]
+runActiveScan = true
+
+[request]
+workDirectory = "`+filepath.ToSlash(workDirectory)+`"
+`)
+
+	cfg, err := ParseConfig(requestFile, "normal")
+	assert.NilError(t, err)
+	assert.True(t, cfg.IsValid("normal"))
+	assert.False(t, cfg.IsValid("api"))
+
+	contexts := cfg.GetContexts()
+	assert.IntsAreEqual(t, 2, len(contexts))
+
+	assert.StringsAreEqual(t, "Context", contexts[0].Context.Name)
+	assert.StringsAreEqual(t, "http://localhost/.*", contexts[0].Context.IncludeRegularExpressions[0])
+	assert.StringsAreEqual(t, "http://localhost/admin.*", contexts[0].Context.ExcludeRegularExpressions[0])
+	assert.False(t, contexts[0].IsAuthenticationEnabled())
+	assert.StringsAreEqual(t, "authHeader", contexts[0].resourceName("authHeader"))
+
+	assert.StringsAreEqual(t, "Admin", contexts[1].Context.Name)
+	assert.True(t, contexts[1].IsContextAuthRequired())
+	assert.IntsAreEqual(t, 1, len(contexts[1].GetCredentials()))
+	assert.StringsAreEqual(t, "admin", contexts[1].GetCredentials()[0].Username)
+	assert.StringsAreEqual(t, "authScript-2", contexts[1].resourceName("authScript"))
+}
+
+func TestLoadContextFile(t *testing.T) {
+
+	const contextXML = `<?xml version="1.0" encoding="UTF-8" standalone="no"?>
+<configuration><context><name>Imported</name><incregexes>http://localhost/.*</incregexes></context></configuration>`
+
+	cfg := Config{}
+	cfg.Context.ContextFile = contextXML
+
+	assert.NilError(t, loadContextFile(&cfg.ContextConfig, &cfg.Request))
+	assert.True(t, cfg.UseContextFile())
+	assert.StringsAreEqual(t, "Imported", cfg.Context.Name)
+	assert.StringsAreEqual(t, contextXML, cfg.GetContextFileContent())
+
+	workDirectory := t.TempDir()
+	inputDirectory := filepath.Join(workDirectory, "input")
+	if err := os.MkdirAll(inputDirectory, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(inputDirectory, "app.context"), []byte(contextXML), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg = Config{}
+	cfg.Request.WorkDirectory = workDirectory
+	cfg.Context.ContextFile = "app.context"
+
+	assert.NilError(t, loadContextFile(&cfg.ContextConfig, &cfg.Request))
+	assert.StringsAreEqual(t, "Imported", cfg.Context.Name)
+
+	cfg.Context.ContextFile = "<configuration/>"
+	assert.NotNil(t, loadContextFile(&cfg.ContextConfig, &cfg.Request))
+}
+
+func TestApplyContextFileUsersWithScriptParameterNames(t *testing.T) {
+
+	cfg := Config{}
+	cfg.Context.ContextFile = `<?xml version="1.0" encoding="UTF-8" standalone="no"?>
+<configuration><context><name>Imported</name><authentication><type>4</type>
+<script><name>login.zst</name><params><param><name>loginUrl</name><value>http://localhost/login</value></param></params></script>
+</authentication></context></configuration>`
+	cfg.Authentication.Type = "scriptAuthentication"
+	cfg.ScriptAuthentication.UsernameParameterName = "login"
+	cfg.ScriptAuthentication.PasswordParameterName = "secret"
+	cfg.credentials = Credentials{{Username: "admin", Password: "s3cret"}}
+	assert.NilError(t, loadContextFile(&cfg.ContextConfig, &cfg.Request))
+
+	client, calls := newFakeZapClient(t, map[string]string{
+		"users/view/getAuthenticationCredentialsConfigParams": `{"credentialsConfigParams": [{"name": "login", "mandatory": "true"}, {"name": "secret", "mandatory": "true"}]}`,
+		"users/view/usersList":                                `{"usersList": []}`,
+		"users/action/newUser":                                `{"userId": "3"}`,
+	})
+
+	credentialString, err := getContextCredentialString(client, &cfg.ContextConfig, "1")
+	assert.NilError(t, err)
+	assert.StringsAreEqual(t, "secret=%s&login=%s", credentialString)
+
+	ctx := Context{ContextID: "1"}
+	assert.NilError(t, applyContextFileUsers(&cfg.ContextConfig, client, &ctx))
+	assert.IntsAreEqual(t, 1, len(ctx.Users))
+	assert.StringsAreEqual(t, "3", ctx.Users[0].UserID)
+	assert.True(t, strings.Contains(strings.Join(calls(), ";"), "users/action/setAuthenticationCredentials"))
+
+	// without script authentication, the credential parameters must be named username and password
+	cfg.Authentication.Type = "formAuthentication"
+	_, err = getContextCredentialString(client, &cfg.ContextConfig, "1")
+	assert.NotNil(t, err)
+}
+
+func TestIsValidAlertFilters(t *testing.T) {
+
+	cfg := Config{}
+	cfg.Context.Name = "Context"
+	cfg.Context.Target = "http://localhost"
+	cfg.AlertFilters = []alertFilter{{RuleID: 10202, NewRisk: "false positive"}}
+	assert.True(t, cfg.IsValid("normal"))
+
+	level, _ := cfg.AlertFilters[0].newLevel()
+	assert.StringsAreEqual(t, "-1", level)
+
+	cfg.AlertFilters = append(cfg.AlertFilters, alertFilter{RuleID: 10202, NewRisk: "Critical"})
+	assert.False(t, cfg.IsValid("normal"))
+
+	cfg.AlertFilters = []alertFilter{{NewRisk: "Low"}}
+	assert.False(t, cfg.IsValid("normal"))
+}
+
+func TestIsValidScanMode(t *testing.T) {
+
+	cfg := Config{}
+	cfg.Context.Name = "Context"
+	cfg.Context.Target = "http://localhost"
+	cfg.ScanOptions.RunActiveScan = true
+	cfg.ScanOptions.Mode = "protected"
+	assert.True(t, cfg.IsValid("normal"))
+
+	cfg.ScanOptions.Mode = "safe"
+	assert.False(t, cfg.IsValid("normal"))
+
+	cfg.ScanOptions.Mode = "attack"
+	assert.False(t, cfg.IsValid("normal"))
+
+	cfg.ScanOptions.Mode = "standard"
+	cfg.Context.Format = "openapi"
+	assert.True(t, cfg.IsValid("api"))
+
+	cfg.ScanOptions.Mode = "safe"
+	assert.False(t, cfg.IsValid("api"))
+
+	cfg.ScanOptions.RunActiveScan = false
+	assert.True(t, cfg.IsValid("api"))
+
+	cfg.ScanOptions.Mode = "attack"
+	assert.False(t, cfg.IsValid("api"))
+}
+
+func TestParseConfigRejectsUnknownKeys(t *testing.T) {
+
+	requestFile := writeRequestFile(t, `
+[context]
+target = "http://localhost/"
+
+[reportOptions]
+minRiskTreshold = 2
+
+[request]
+workDirectory = "`+filepath.ToSlash(t.TempDir())+`"
+imageName = "codedx/codedx-zaprunner"
+`)
+
+	_, err := ParseConfig(requestFile, "normal")
+	assert.NotNil(t, err)
+	assert.True(t, strings.Contains(err.Error(), "minrisktreshold"))
+}
+
+func TestParseConfigReaderFormats(t *testing.T) {
+
+	workDirectory := filepath.ToSlash(t.TempDir())
+
+	jsonConfig, err := ParseConfigReader(strings.NewReader(`{
+  "context": {"target": "http://localhost/json"},
+  "reportOptions": {"minRiskThreshold": 1},
+  "request": {"workDirectory": "`+workDirectory+`", "shellCmd": "zap"}
+}`), "normal")
+	assert.NilError(t, err)
+	assert.StringsAreEqual(t, "http://localhost/json", jsonConfig.Context.Target)
+	assert.IntsAreEqual(t, 1, jsonConfig.ReportOptions.MinRiskThreshold)
+
+	yamlConfig, err := ParseConfigReader(strings.NewReader(`
+context:
+  target: http://localhost/yaml
+request:
+  workDirectory: `+workDirectory+`
+`), "normal")
+	assert.NilError(t, err)
+	assert.StringsAreEqual(t, "http://localhost/yaml", yamlConfig.Context.Target)
+	assert.IntsAreEqual(t, 0, yamlConfig.ReportOptions.MinRiskThreshold)
+
+	tomlConfig, err := ParseConfigReader(strings.NewReader(`
+[context]
+target = "http://localhost/toml"
+[request]
+workDirectory = "`+workDirectory+`"
+`), "normal")
+	assert.NilError(t, err)
+	assert.StringsAreEqual(t, "http://localhost/toml", tomlConfig.Context.Target)
+	assert.StringsAreEqual(t, "http://localhost/json", jsonConfig.Context.Target)
+}
+
+func TestApiScanContextIncludesAlertFilters(t *testing.T) {
+
+	cfg, err := ParseConfigReader(strings.NewReader(`
+[context]
+target = "openapi.json"
+format = "openapi"
+
+[[alertFilters]]
+ruleId = 10202
+newRisk = "False Positive"
+`), "api")
+	assert.NilError(t, err)
+
+	assert.True(t, cfg.IsContextFileRequired())
+	assert.StringsAreEqual(t, ".*", strings.Join(cfg.Context.IncludeRegularExpressions, ";"))
+	assert.StringsAreEqual(t, "the context exported from a temporary ZAP instance", ApiScanFiles(cfg, "wrk")[0].Description)
+	assert.True(t, strings.Contains(strings.Join(ApiScanArguments(cfg, "zap-api-scan.py", "wrk"), " "), "-n context.xml"))
+}
@@ -0,0 +1,243 @@
+package zap
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"strings"
+
+	"github.com/zaproxy/zap-api-go/zap"
+)
+
+type contextFileDocument struct {
+	Context struct {
+		Name           string `xml:"name"`
+		Authentication struct {
+			LoggedOut string `xml:"loggedout"`
+		} `xml:"authentication"`
+	} `xml:"context"`
+}
+
+// UseContextFile reports whether the context comes from a user-supplied ZAP context file.
+func (c *ContextConfig) UseContextFile() bool {
+	return c.Context.ContextFile != ""
+}
+
+// IsContextExportRequired reports whether an API scan's context file is exported from a temporary ZAP instance
+// instead of being written from the user-supplied context file, which does not contain the loaded credentials or
+// the request file's context settings.
+func (c *ContextConfig) IsContextExportRequired() bool {
+	return !c.UseContextFile() || c.IsContextAuthRequired() || len(c.Context.IncludeRegularExpressions) > 0 || len(c.Context.ExcludeRegularExpressions) > 0 || c.hasContextSettings()
+}
+
+// GetContextFileContent returns the content of the user-supplied ZAP context file.
+func (c *ContextConfig) GetContextFileContent() string {
+	return c.contextFileContent
+}
+
+// loadContextFile reads the user-supplied ZAP context file, which is either inline XML content or a path that is
+// absolute or relative to the analysis input directory, and sets the context name to the one in the file.
+func loadContextFile(config *ContextConfig, request *request) error {
+
+	if !config.UseContextFile() {
+		return nil
+	}
+
+	content := strings.TrimSpace(config.Context.ContextFile)
+	if !strings.HasPrefix(content, "<") {
+		b, err := ioutil.ReadFile(request.resolveInputPath(content))
+		if err != nil {
+			return err
+		}
+		content = string(b)
+	}
+
+	var doc contextFileDocument
+	if err := xml.Unmarshal([]byte(content), &doc); err != nil {
+		return fmt.Errorf("unable to read context file: %s", err.Error())
+	}
+	if doc.Context.Name == "" {
+		return errors.New("unable to find the context name in the context file")
+	}
+
+	if config.HasContextStructure() {
+		structuredContent, err := applyContextStructure(content, config.Context.StructuralParameters, config.Context.DataDrivenNodes)
+		if err != nil {
+			return err
+		}
+		content = structuredContent
+	}
+
+	config.contextFileContent = content
+	config.contextFileLoggedOutIndicator = doc.Context.Authentication.LoggedOut
+	config.Context.Name = doc.Context.Name
+	return nil
+}
+
+// importContext imports a user-supplied ZAP context file and applies loaded credentials to the context's users.
+func importContext(zap *zap.Interface, cfg *ContextConfig, authScriptFile string) (Context, error) {
+
+	var ctx Context
+
+	if cfg.UseScriptAuthentication() && cfg.ScriptAuthentication.AuthenticationScriptContent != "" {
+		// the context file's script-based authentication refers to a script that must already be loaded
+		if err := loadAuthenticationScriptFile(cfg.ScriptAuthentication, cfg.resourceName("authScript"), authScriptFile, zap); err != nil {
+			return ctx, err
+		}
+	}
+
+	xf, err := ioutil.TempFile("", "context")
+	if err != nil {
+		return ctx, err
+	}
+	defer func() {
+		if err := SecureDelete(xf.Name()); err != nil {
+			log.Println(err)
+		}
+	}()
+
+	if _, err := xf.WriteString(cfg.contextFileContent); err != nil {
+		if err := xf.Close(); err != nil {
+			log.Println(err)
+		}
+		return ctx, err
+	}
+	if err := xf.Close(); err != nil {
+		return ctx, err
+	}
+
+	result, err := (*zap).Context().ImportContext(xf.Name())
+	if err != nil {
+		return ctx, err
+	}
+
+	ctx.ContextName = cfg.Context.Name
+	ctx.ContextID, err = getZapStringResult("contextId", result)
+	if err != nil {
+		return ctx, err
+	}
+
+	if err := addContextIncludes(cfg.Context.IncludeRegularExpressions, cfg.Context.Name, zap); err != nil {
+		return ctx, err
+	}
+
+	if err := addContextExcludes(cfg.Context.ExcludeRegularExpressions, cfg.Context.Name, zap); err != nil {
+		return ctx, err
+	}
+
+	if err := addAntiCrossSiteRequestForgeryTokens(cfg.Context.AntiCrossSiteRequestForgeryTokenNames, zap); err != nil {
+		return ctx, err
+	}
+
+	if err := configureContextTechnologies(cfg.Context.IncludeTechnologies, cfg.Context.ExcludeTechnologies, cfg.Context.Name, zap); err != nil {
+		return ctx, err
+	}
+
+	if err := addAlertFilters(cfg.AlertFilters, zap, &ctx); err != nil {
+		return ctx, err
+	}
+
+	if !cfg.IsContextAuthRequired() {
+		return ctx, nil
+	}
+
+	if err := applyContextFileUsers(cfg, zap, &ctx); err != nil {
+		return ctx, err
+	}
+	return ctx, nil
+}
+
+// applyContextFileUsers sets the credentials of context file users whose names match loaded credentials and adds
+// users for the remaining credentials.
+func applyContextFileUsers(cfg *ContextConfig, zap *zap.Interface, ctx *Context) error {
+
+	credentialString, err := getContextCredentialString(zap, cfg, ctx.ContextID)
+	if err != nil {
+		return err
+	}
+
+	result, err := (*zap).Users().UsersList(ctx.ContextID)
+	if err != nil {
+		return err
+	}
+	usersList, err := getZapResult("usersList", result)
+	if err != nil {
+		return err
+	}
+
+	userIDs := make(map[string]string)
+	list, _ := usersList.([]interface{})
+	for _, u := range list {
+		user, ok := u.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := user["name"].(string)
+		id, _ := user["id"].(string)
+		userIDs[name] = id
+	}
+
+	for _, cred := range cfg.credentials {
+		userID, ok := userIDs[cred.Username]
+		if ok {
+			if err := setUserCredentials(zap, ctx.ContextID, userID, cred.Username, cred.Password, credentialString); err != nil {
+				return err
+			}
+		} else {
+			if userID, err = addUser(zap, ctx.ContextID, cred.Username, cred.Password, credentialString); err != nil {
+				return err
+			}
+		}
+		ctx.Users = append(ctx.Users, User{
+			UserID:     userID,
+			Credential: cred,
+		})
+	}
+	return nil
+}
+
+// getContextCredentialString returns the format of the credentials for the context's authentication method, where
+// the password precedes the username. The credential parameters of script authentication have the configured
+// usernameParameterName and passwordParameterName, and those of other methods are named username and password.
+func getContextCredentialString(zap *zap.Interface, cfg *ContextConfig, contextID string) (string, error) {
+
+	usernameName := "username"
+	passwordName := "password"
+	if cfg.UseScriptAuthentication() {
+		usernameName = cfg.ScriptAuthentication.UsernameParameterName
+		passwordName = cfg.ScriptAuthentication.PasswordParameterName
+	}
+
+	result, err := (*zap).Users().GetAuthenticationCredentialsConfigParams(contextID)
+	if err != nil {
+		return "", err
+	}
+	configParams, err := getZapResult("credentialsConfigParams", result)
+	if err != nil {
+		return "", err
+	}
+
+	usernameParam := ""
+	passwordParam := ""
+	list, _ := configParams.([]interface{})
+	for _, p := range list {
+		param, ok := p.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := param["name"].(string)
+		if strings.EqualFold(name, usernameName) {
+			usernameParam = name
+		} else if strings.EqualFold(name, passwordName) {
+			passwordParam = name
+		}
+	}
+
+	if usernameParam == "" || passwordParam == "" {
+		return "", fmt.Errorf("the context file's authentication method does not accept %s and %s credential parameters", usernameName, passwordName)
+	}
+	return fmt.Sprintf("%s=%%s&%s=%%s", url.QueryEscape(passwordParam), url.QueryEscape(usernameParam)), nil
+}
@@ -0,0 +1,357 @@
+package zap
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// The names of the secrets that make up a credential, which are also the default workflow secret file names.
+const (
+	usernameSecret     = "username"
+	passwordSecret     = "password"
+	headerValueSecret  = "header-value"
+	clientIDSecret     = "client-id"
+	clientSecretSecret = "client-secret"
+	refreshTokenSecret = "refresh-token"
+	totpSecretSecret   = "totp-secret"
+)
+
+// credentialSources configures where a context reads its credentials. Workflow secrets are always read, and a
+// credentials file and environment variables add more users.
+type credentialSources struct {
+	SecretFileNames   secretFileNames // the file names in each workflow secret directory
+	File              string          // a JSON or YAML credentials file, absolute or relative to the analysis input directory
+	EnvironmentPrefix string          // the prefix of credential environment variables, such as APP_CREDENTIAL_
+}
+
+// secretFileNames maps each secret to its file name in a workflow secret directory.
+type secretFileNames struct {
+	Username     string
+	Password     string
+	HeaderValue  string
+	ClientID     string
+	ClientSecret string
+	RefreshToken string
+	TotpSecret   string
+}
+
+func (n *secretFileNames) applyDefaults() {
+	setDefault := func(value *string, defaultValue string) {
+		if *value == "" {
+			*value = defaultValue
+		}
+	}
+	setDefault(&n.Username, usernameSecret)
+	setDefault(&n.Password, passwordSecret)
+	setDefault(&n.HeaderValue, headerValueSecret)
+	setDefault(&n.ClientID, clientIDSecret)
+	setDefault(&n.ClientSecret, clientSecretSecret)
+	setDefault(&n.RefreshToken, refreshTokenSecret)
+	setDefault(&n.TotpSecret, totpSecretSecret)
+}
+
+func (n *secretFileNames) fileName(secret string) string {
+	return map[string]string{
+		usernameSecret:     n.Username,
+		passwordSecret:     n.Password,
+		headerValueSecret:  n.HeaderValue,
+		clientIDSecret:     n.ClientID,
+		clientSecretSecret: n.ClientSecret,
+		refreshTokenSecret: n.RefreshToken,
+		totpSecretSecret:   n.TotpSecret,
+	}[secret]
+}
+
+// credentialsFileUser is a user in a credentials file.
+type credentialsFileUser struct {
+	Name         string // the name that the context's workflowSecrets list can refer to
+	Username     string
+	Password     string
+	HeaderValue  string
+	ClientID     string
+	ClientSecret string
+	RefreshToken string
+	TotpSecret   string
+	Metadata     UserMetadata
+}
+
+func (u *credentialsFileUser) secret(secret string) string {
+	return map[string]string{
+		usernameSecret:     u.Username,
+		passwordSecret:     u.Password,
+		headerValueSecret:  u.HeaderValue,
+		clientIDSecret:     u.ClientID,
+		clientSecretSecret: u.ClientSecret,
+		refreshTokenSecret: u.RefreshToken,
+		totpSecretSecret:   u.TotpSecret,
+	}[secret]
+}
+
+// credentialEnvironmentSecrets lists the environment variable suffix of each secret.
+var credentialEnvironmentSecrets = map[string]string{
+	"_USERNAME":      usernameSecret,
+	"_PASSWORD":      passwordSecret,
+	"_HEADER_VALUE":  headerValueSecret,
+	"_CLIENT_ID":     clientIDSecret,
+	"_CLIENT_SECRET": clientSecretSecret,
+	"_REFRESH_TOKEN": refreshTokenSecret,
+	"_TOTP_SECRET":   totpSecretSecret,
+}
+
+// environ returns the environment that credential environment variables are read from.
+var environ = os.Environ
+
+// secretReader returns the value of a secret, such as password, for a user or an empty string when the secret is
+// missing.
+type secretReader func(secret string) (string, error)
+
+// loadCredentials reads the context's credentials from workflow secret directories, the credentials file, and
+// environment variables, in that order.
+func loadCredentials(config *ContextConfig, request *request, scanMode string) error {
+
+	if config.credentials == nil {
+		config.credentials = make([]Credential, 0)
+	}
+
+	if err := loadWorkflowSecretCredentials(config, request, scanMode); err != nil {
+		return err
+	}
+	if err := loadCredentialsFile(config, request, scanMode); err != nil {
+		return err
+	}
+	return loadEnvironmentCredentials(config, scanMode)
+}
+
+// loadWorkflowSecretCredentials reads a credential from each workflow secret directory.
+func loadWorkflowSecretCredentials(config *ContextConfig, request *request, scanMode string) error {
+
+	credentialsDirectory := request.GetWorkflowSecretsDirectory()
+	if credentialsDirectory == "" {
+		return nil
+	}
+
+	fileNames := config.CredentialSources.SecretFileNames
+	fileNames.applyDefaults()
+
+	return filepath.Walk(credentialsDirectory, func(filePath string, info os.FileInfo, e error) error {
+
+		if filePath == credentialsDirectory || !info.IsDir() {
+			return nil
+		}
+
+		if !config.usesWorkflowSecret(info.Name()) {
+			return filepath.SkipDir
+		}
+
+		metadata, err := readUserMetadata(filePath)
+		if err != nil {
+			return err
+		}
+
+		readSecret := func(secret string) (string, error) {
+			return readOptionalSecret(filePath, fileNames.fileName(secret))
+		}
+		if err := config.addCredential(fmt.Sprintf("workflow secret %s", info.Name()), readSecret, metadata, scanMode); err != nil {
+			return err
+		}
+		return filepath.SkipDir
+	})
+}
+
+// loadCredentialsFile reads a credential for each user in the context's JSON or YAML credentials file.
+func loadCredentialsFile(config *ContextConfig, request *request, scanMode string) error {
+
+	if config.CredentialSources.File == "" {
+		return nil
+	}
+
+	credentialsFile := request.resolveInputPath(config.CredentialSources.File)
+	content, err := ioutil.ReadFile(credentialsFile)
+	if err != nil {
+		return err
+	}
+
+	format := "yaml"
+	if strings.EqualFold(filepath.Ext(credentialsFile), ".json") {
+		format = "json"
+	}
+
+	v := viper.New()
+	v.SetConfigType(format)
+	if err := v.ReadConfig(strings.NewReader(string(content))); err != nil {
+		return fmt.Errorf("unable to read credentials file %s: %s", credentialsFile, err.Error())
+	}
+
+	var users []credentialsFileUser
+	if err := v.UnmarshalKey("users", &users); err != nil {
+		return fmt.Errorf("unable to read credentials file %s: %s", credentialsFile, err.Error())
+	}
+
+	for i := range users {
+		user := users[i]
+		if user.Name != "" && !config.usesWorkflowSecret(user.Name) {
+			continue
+		}
+
+		readSecret := func(secret string) (string, error) {
+			return strings.TrimSpace(user.secret(secret)), nil
+		}
+		if err := config.addCredential(fmt.Sprintf("credentials file user %d", i+1), readSecret, user.Metadata, scanMode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadEnvironmentCredentials reads a credential for each user named by environment variables such as
+// {prefix}ADMIN_USERNAME and {prefix}ADMIN_PASSWORD. The lowercase user name (e.g., admin) is the name that the
+// context's workflowSecrets list can refer to.
+func loadEnvironmentCredentials(config *ContextConfig, scanMode string) error {
+
+	prefix := config.CredentialSources.EnvironmentPrefix
+	if prefix == "" {
+		return nil
+	}
+
+	users := make(map[string]map[string]string)
+	for _, variable := range environ() {
+
+		nameValue := strings.SplitN(variable, "=", 2)
+		if len(nameValue) != 2 || !strings.HasPrefix(nameValue[0], prefix) {
+			continue
+		}
+
+		name := strings.TrimPrefix(nameValue[0], prefix)
+		for suffix, secret := range credentialEnvironmentSecrets {
+			// no suffix ends another, so at most one matches
+			if user := strings.TrimSuffix(name, suffix); user != name && user != "" {
+				user = strings.ToLower(user)
+				if users[user] == nil {
+					users[user] = make(map[string]string)
+				}
+				users[user][secret] = strings.TrimSpace(nameValue[1])
+				break
+			}
+		}
+	}
+
+	names := make([]string, 0, len(users))
+	for name := range users {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if !config.usesWorkflowSecret(name) {
+			continue
+		}
+
+		secrets := users[name]
+		readSecret := func(secret string) (string, error) {
+			return secrets[secret], nil
+		}
+		if err := config.addCredential(fmt.Sprintf("environment user %s", name), readSecret, UserMetadata{}, scanMode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addCredential reads the secrets that the context's authentication type requires and adds the credential.
+func (c *ContextConfig) addCredential(source string, readSecret secretReader, metadata UserMetadata, scanMode string) error {
+
+	if (IsApiScan(scanMode) || c.UseHeaderAuthentication() || c.UseOAuth2Authentication()) && len(c.credentials) > 0 {
+		return errors.New("only one credential can be defined")
+	}
+
+	readSecrets := func(secrets ...string) ([]string, error) {
+		values := make([]string, len(secrets))
+		for i, secret := range secrets {
+			value, err := readSecret(secret)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = value
+		}
+		return values, nil
+	}
+
+	var cred Credential
+	if c.UseOAuth2Authentication() {
+		values, err := readSecrets(clientIDSecret, clientSecretSecret, refreshTokenSecret)
+		if err != nil {
+			return err
+		}
+		cred = Credential{Username: values[0], Password: values[1], RefreshToken: values[2]}
+		if cred.RefreshToken == "" && (cred.Username == "" || cred.Password == "") {
+			return errors.New("oauth2Authentication requires either a client-id and client-secret or a refresh-token")
+		}
+	} else if c.UseHeaderAuthentication() {
+		values, err := readSecrets(headerValueSecret)
+		if err != nil {
+			return err
+		}
+		if values[0] == "" {
+			return fmt.Errorf("%s has no %s", source, headerValueSecret)
+		}
+		cred = Credential{Password: values[0]}
+	} else {
+		values, err := readSecrets(usernameSecret, passwordSecret, totpSecretSecret)
+		if err != nil {
+			return err
+		}
+		if values[0] == "" || values[1] == "" {
+			return fmt.Errorf("%s requires a %s and a %s", source, usernameSecret, passwordSecret)
+		}
+		cred = Credential{Username: values[0], Password: values[1], TotpSecret: values[2]}
+
+		if cred.TotpSecret != "" && IsApiScan(scanMode) {
+			// zap-api-scan runs its own ZAP daemon without the background TOTP refresh that keeps the
+			// {%totp%} placeholder current, so API scans cannot log in with a TOTP code
+			return fmt.Errorf("%s has a %s, which api scans do not support because the {%%totp%%} placeholder is only replaced during normal scans", source, totpSecretSecret)
+		}
+		if cred.TotpSecret != "" {
+			if _, err := GenerateTOTP(cred.TotpSecret, time.Now()); err != nil {
+				return err
+			}
+		}
+	}
+
+	cred.Metadata = metadata
+	c.credentials = append(c.credentials, cred)
+	return nil
+}
+
+// usesWorkflowSecret reports whether the context reads credentials from the named workflow secret.
+func (c *ContextConfig) usesWorkflowSecret(name string) bool {
+	if len(c.WorkflowSecrets) == 0 {
+		return true
+	}
+	for _, s := range c.WorkflowSecrets {
+		if s == name {
+			return true
+		}
+	}
+	return false
+}
+
+// readOptionalSecret returns the contents of a secret file or an empty string when the file does not exist.
+func readOptionalSecret(directory string, name string) (string, error) {
+	b, err := ioutil.ReadFile(filepath.FromSlash(path.Join(directory, name)))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
@@ -0,0 +1,123 @@
+package zap
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/codedx/codedx-add-ins/pkg/assert"
+)
+
+func TestLoadCredentialsUsesSecretFileNames(t *testing.T) {
+
+	workDirectory := t.TempDir()
+	writeSecret(t, workDirectory, "admin", "user", "admin")
+	writeSecret(t, workDirectory, "admin", "pass", "s3cret\n")
+
+	cfg := Config{}
+	cfg.Request.WorkDirectory = workDirectory
+	cfg.Authentication.Type = "formAuthentication"
+
+	assert.NotNil(t, loadCredentials(&cfg.ContextConfig, &cfg.Request, "normal"))
+
+	cfg.credentials = nil
+	cfg.CredentialSources.SecretFileNames = secretFileNames{Username: "user", Password: "pass"}
+	assert.NilError(t, loadCredentials(&cfg.ContextConfig, &cfg.Request, "normal"))
+	assert.IntsAreEqual(t, 1, len(cfg.GetCredentials()))
+	assert.StringsAreEqual(t, "admin", cfg.GetCredentials()[0].Username)
+	assert.StringsAreEqual(t, "s3cret", cfg.GetCredentials()[0].Password)
+}
+
+func TestLoadCredentialsReadsCredentialsFile(t *testing.T) {
+
+	workDirectory := t.TempDir()
+	inputDirectory := filepath.Join(workDirectory, "input")
+	if err := os.MkdirAll(inputDirectory, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	const yamlCredentials = `users:
+  - name: admin
+    username: admin
+    password: s3cret
+    metadata:
+      role: administrator
+      startURLs: ["http://localhost/admin"]
+  - name: reader
+    username: reader
+    password: s3cret
+`
+	if err := os.WriteFile(filepath.Join(inputDirectory, "users.yaml"), []byte(yamlCredentials), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	const jsonCredentials = `{"users": [{"name": "api", "clientId": "id", "clientSecret": "secret"}]}`
+	if err := os.WriteFile(filepath.Join(inputDirectory, "users.json"), []byte(jsonCredentials), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := Config{}
+	cfg.Request.WorkDirectory = workDirectory
+	cfg.Authentication.Type = "formAuthentication"
+	cfg.CredentialSources.File = "users.yaml"
+
+	assert.NilError(t, loadCredentials(&cfg.ContextConfig, &cfg.Request, "normal"))
+	assert.IntsAreEqual(t, 2, len(cfg.GetCredentials()))
+	assert.StringsAreEqual(t, "administrator", cfg.GetCredentials()[0].Metadata.Role)
+	assert.StringsAreEqual(t, "http://localhost/admin", cfg.GetCredentials()[0].Metadata.StartURLs[0])
+
+	cfg.credentials = nil
+	cfg.WorkflowSecrets = []string{"reader"}
+	assert.NilError(t, loadCredentials(&cfg.ContextConfig, &cfg.Request, "normal"))
+	assert.IntsAreEqual(t, 1, len(cfg.GetCredentials()))
+	assert.StringsAreEqual(t, "reader", cfg.GetCredentials()[0].Username)
+
+	cfg.credentials = nil
+	cfg.WorkflowSecrets = nil
+	cfg.Authentication.Type = "oauth2Authentication"
+	cfg.CredentialSources.File = "users.json"
+	assert.NilError(t, loadCredentials(&cfg.ContextConfig, &cfg.Request, "normal"))
+	assert.StringsAreEqual(t, "id", cfg.GetCredentials()[0].Username)
+	assert.StringsAreEqual(t, "secret", cfg.GetCredentials()[0].Password)
+}
+
+func TestLoadCredentialsReadsEnvironment(t *testing.T) {
+
+	defer func(e func() []string) { environ = e }(environ)
+	environ = func() []string {
+		return []string{
+			"APP_CREDENTIAL_SUPPORT_ADMIN_USERNAME=admin",
+			"APP_CREDENTIAL_SUPPORT_ADMIN_PASSWORD=s3cret",
+			"APP_CREDENTIAL_READER_USERNAME=reader",
+			"APP_CREDENTIAL_READER_PASSWORD=s3cret",
+			"APP_CREDENTIAL_WRITER_USERNAME=writer",
+			"PATH=/usr/bin",
+		}
+	}
+
+	cfg := Config{}
+	cfg.Authentication.Type = "formAuthentication"
+	cfg.CredentialSources.EnvironmentPrefix = "APP_CREDENTIAL_"
+
+	// the writer has no password
+	assert.NotNil(t, loadCredentials(&cfg.ContextConfig, &cfg.Request, "normal"))
+
+	cfg.credentials = nil
+	cfg.WorkflowSecrets = []string{"reader", "support_admin"}
+	assert.NilError(t, loadCredentials(&cfg.ContextConfig, &cfg.Request, "normal"))
+	assert.IntsAreEqual(t, 2, len(cfg.GetCredentials()))
+	assert.StringsAreEqual(t, "reader", cfg.GetCredentials()[0].Username)
+	assert.StringsAreEqual(t, "admin", cfg.GetCredentials()[1].Username)
+}
+
+func TestValidateCredentialEnvironmentPrefix(t *testing.T) {
+
+	cfg := Config{}
+	cfg.Context.Name = "Context"
+	cfg.Context.Target = "http://localhost"
+	cfg.CredentialSources.EnvironmentPrefix = "ZAPRUNNER_USER_"
+
+	keys := validationErrorKeys(t, cfg.Validate("normal"))
+	assert.IntsAreEqual(t, 1, len(keys))
+	assert.StringsAreEqual(t, "credentialSources.environmentPrefix", keys[0])
+}
@@ -0,0 +1,115 @@
+package zap
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+const redactedValue = "[REDACTED]"
+
+// sensitiveNames matches the names of script parameters whose values are redacted from the effective configuration.
+var sensitiveNames = regexp.MustCompile(`(?i)(password|secret|token|key|credential)`)
+
+// EffectiveConfig returns the configuration, after overrides and defaults, as a JSON document whose keys match
+// request file keys. Values containing a secret the configuration's Redactor knows, such as a credential value,
+// and secret script parameters are redacted, and multi-line content, such as an authentication script, is summarized.
+func (c *Config) EffectiveConfig() (string, error) {
+
+	b, err := json.MarshalIndent(effectiveValue(reflect.ValueOf(*c), c.Redactor()), "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+func effectiveValue(v reflect.Value, redactor *Redactor) interface{} {
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return effectiveValue(v.Elem(), redactor)
+
+	case reflect.Struct:
+		values := make(map[string]interface{})
+		addEffectiveFields(v, values, redactor)
+		if v.Type() == reflect.TypeOf(scriptParameter{}) && sensitiveNames.MatchString(v.Interface().(scriptParameter).Name) {
+			values["value"] = redactedValue
+		}
+		return values
+
+	case reflect.Slice, reflect.Array:
+		values := make([]interface{}, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			values[i] = effectiveValue(v.Index(i), redactor)
+		}
+		return values
+
+	case reflect.Map:
+		values := make(map[string]interface{})
+		for _, key := range v.MapKeys() {
+			values[fmt.Sprint(key.Interface())] = effectiveValue(v.MapIndex(key), redactor)
+		}
+		return values
+
+	case reflect.String:
+		s := v.String()
+		if lines := strings.Count(s, "\n"); lines > 0 {
+			return fmt.Sprintf("[%d lines]", lines+1)
+		}
+		if redactor.Redact(s) != s {
+			return redactedValue
+		}
+		return s
+	}
+	return v.Interface()
+}
+
+// addEffectiveFields adds the exported fields of a struct, including the fields of squashed structs, using their
+// request file key names. The remaining request keys reserved for Code Dx are omitted.
+func addEffectiveFields(v reflect.Value, values map[string]interface{}, redactor *Redactor) {
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		tag := field.Tag.Get("mapstructure")
+		if tag == ",squash" {
+			addEffectiveFields(v.Field(i), values, redactor)
+			continue
+		}
+		if tag == ",remain" {
+			continue
+		}
+
+		values[configKeyName(field.Name)] = effectiveValue(v.Field(i), redactor)
+	}
+}
+
+// configKeyNames lists the request file keys for field names whose acronyms the keys do not capitalize.
+var configKeyNames = map[string]string{
+	"ClientID":             "clientId",
+	"OAuth2Authentication": "oauth2Authentication",
+	"RuleID":               "ruleId",
+	"URL":                  "url",
+	"URLRegularExpression": "urlRegularExpression",
+}
+
+// configKeyName returns the request file key for a field name (e.g., formURL for FormURL).
+func configKeyName(fieldName string) string {
+	if key, ok := configKeyNames[fieldName]; ok {
+		return key
+	}
+	runes := []rune(fieldName)
+	runes[0] = unicode.ToLower(runes[0])
+	return string(runes)
+}
@@ -0,0 +1,52 @@
+package zap
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/codedx/codedx-add-ins/pkg/assert"
+)
+
+func TestEffectiveConfigRedactsSecrets(t *testing.T) {
+
+	cfg := Config{}
+	cfg.Context.Target = "http://localhost/"
+	cfg.Authentication.Type = "scriptAuthentication"
+	cfg.ScriptAuthentication.AuthenticationScriptContent = "line 1\nline 2"
+	cfg.ScriptAuthentication.AuthenticationScriptParameters = []scriptParameter{{Name: "apiKey", Value: "k1"}, {Name: "loginUrl", Value: "http://localhost/login"}}
+	cfg.FormAuthentication.FormExtraPostData = "pin=s3cret"
+	cfg.Request.Reserved = map[string]interface{}{"shellCmd": "zap"}
+	cfg.credentials = Credentials{{Username: "user", Password: "s3cret"}}
+
+	effectiveConfig, err := cfg.EffectiveConfig()
+	assert.NilError(t, err)
+
+	assert.True(t, strings.Contains(effectiveConfig, `"target": "http://localhost/"`))
+	assert.True(t, strings.Contains(effectiveConfig, `"authenticationScriptContent": "[2 lines]"`))
+	assert.True(t, strings.Contains(effectiveConfig, `"formExtraPostData": "[REDACTED]"`))
+	assert.True(t, strings.Contains(effectiveConfig, `"value": "http://localhost/login"`))
+	assert.False(t, strings.Contains(effectiveConfig, "k1"))
+	assert.False(t, strings.Contains(effectiveConfig, "s3cret"))
+	assert.False(t, strings.Contains(effectiveConfig, "shellCmd"))
+}
+
+func TestEffectiveConfigUsesRedactor(t *testing.T) {
+
+	cfg := Config{}
+	cfg.Context.Target = "http://localhost/"
+	cfg.Authentication.Type = "headerAuthentication"
+	cfg.HeaderAuthentication.AuthHeaderSite = "localhost"
+	cfg.FormAuthentication.FormExtraPostData = "auth=dXNlcjpzM2NyZXQ="
+	cfg.credentials = Credentials{{Username: "user", Password: "s3cret"}}
+
+	// a secret found during the scan, such as a fetched access token
+	cfg.Redactor().Add("fetched-token")
+	cfg.OAuth2Authentication.Scope = "fetched-token"
+
+	effectiveConfig, err := cfg.EffectiveConfig()
+	assert.NilError(t, err)
+
+	assert.True(t, strings.Contains(effectiveConfig, `"formExtraPostData": "[REDACTED]"`))
+	assert.True(t, strings.Contains(effectiveConfig, `"scope": "[REDACTED]"`))
+	assert.True(t, strings.Contains(effectiveConfig, `"authHeaderSite": "localhost"`))
+}
@@ -0,0 +1,137 @@
+package zap
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// mergedBaseLists lists the keys of the lists that combine a base request file's values with an overlay's instead of
+// being replaced, unless the overlay names the list's key path in replaceBaseLists.
+var mergedBaseLists = map[string]bool{
+	"includeregularexpressions": true,
+	"excluderegularexpressions": true,
+}
+
+// extendSettings merges request file settings on top of the settings of the base request file named by extends,
+// which can itself extend another base request file. A relative base request file path is relative to the analysis
+// input directory of the request file being parsed.
+func extendSettings(settings map[string]interface{}, request *request, extended map[string]bool) (map[string]interface{}, error) {
+
+	extendsKey, ok := findSettingKey(settings, "extends")
+	if !ok {
+		return settings, nil
+	}
+	baseFile, _ := settings[extendsKey].(string)
+	if baseFile == "" {
+		return settings, nil
+	}
+
+	basePath, err := filepath.Abs(request.resolveInputPath(baseFile))
+	if err != nil {
+		return nil, err
+	}
+	if extended[basePath] {
+		return nil, fmt.Errorf("base request file %s extends itself", basePath)
+	}
+	extended[basePath] = true
+
+	content, err := ioutil.ReadFile(basePath)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Extending base request file %s...", basePath)
+	baseSettings, err := readSettings(content, ConfigFormat(basePath, content))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read base request file %s: %s", basePath, err.Error())
+	}
+
+	baseSettings, err = extendSettings(baseSettings, request, extended)
+	if err != nil {
+		return nil, err
+	}
+
+	replaceLists := make(map[string]bool)
+	if key, ok := findSettingKey(settings, "replaceBaseLists"); ok {
+		values, _ := settings[key].([]interface{})
+		for _, value := range values {
+			replaceLists[strings.ToLower(fmt.Sprint(value))] = true
+		}
+	}
+
+	mergeSettings(baseSettings, settings, "", replaceLists)
+	return baseSettings, nil
+}
+
+// mergeSettings deep-merges overlay settings into base settings, whose keys may use any case. Sections merge key by
+// key, the include and exclude regular expression lists combine the base's values with the overlay's, and other
+// values, including lists such as contexts and alertFilters, replace the base's.
+func mergeSettings(base map[string]interface{}, overlay map[string]interface{}, path string, replaceLists map[string]bool) {
+
+	for key, value := range overlay {
+
+		keyPath := path + strings.ToLower(key)
+
+		baseKey, ok := findSettingKey(base, key)
+		if !ok {
+			base[key] = value
+			continue
+		}
+		baseValue := base[baseKey]
+		delete(base, baseKey)
+
+		if overlayMap, ok := settingMap(value); ok {
+			if baseMap, ok := settingMap(baseValue); ok {
+				mergeSettings(baseMap, overlayMap, keyPath+".", replaceLists)
+				base[key] = baseMap
+				continue
+			}
+		}
+
+		if mergedBaseLists[strings.ToLower(key)] && !replaceLists[keyPath] {
+			baseList, baseOk := baseValue.([]interface{})
+			overlayList, overlayOk := value.([]interface{})
+			if baseOk && overlayOk {
+				base[key] = appendUniqueSettings(baseList, overlayList)
+				continue
+			}
+		}
+
+		base[key] = value
+	}
+}
+
+// appendUniqueSettings returns the base values followed by the overlay values that are not already present.
+func appendUniqueSettings(base []interface{}, overlay []interface{}) []interface{} {
+
+	values := append(make([]interface{}, 0, len(base)+len(overlay)), base...)
+	for _, value := range overlay {
+		found := false
+		for _, v := range values {
+			if reflect.DeepEqual(v, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// settingsRequest returns the request section of request file settings, which locates the analysis input directory.
+func settingsRequest(settings map[string]interface{}) *request {
+
+	r := &request{}
+	if requestSettings, ok := findSettingMap(settings, "request"); ok {
+		if key, ok := findSettingKey(requestSettings, "workDirectory"); ok {
+			r.WorkDirectory, _ = requestSettings[key].(string)
+		}
+	}
+	return r
+}
@@ -0,0 +1,126 @@
+package zap
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/codedx/codedx-add-ins/pkg/assert"
+)
+
+func writeInputFile(t *testing.T, workDirectory string, name string, content string) string {
+	dir := filepath.Join(workDirectory, "input")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	filePath := filepath.Join(dir, name)
+	if err := os.WriteFile(filePath, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return filePath
+}
+
+func TestParseConfigExtendsBaseRequestFile(t *testing.T) {
+
+	workDirectory := t.TempDir()
+	writeInputFile(t, workDirectory, "org-base.yaml", `
+extends: org-root.toml
+context:
+  excludeRegularExpressions: [".*/logout.*"]
+reportOptions:
+  minRiskThreshold: 2
+`)
+	writeInputFile(t, workDirectory, "org-root.toml", `
+[context]
+excludeRegularExpressions = [".*/signout.*"]
+
+[scope]
+allowedHosts = ["localhost"]
+`)
+
+	cfg, err := ParseConfigReader(strings.NewReader(fmt.Sprintf(`
+extends = "org-base.yaml"
+
+[context]
+target = "http://localhost/"
+excludeRegularExpressions = [".*/admin.*", ".*/logout.*"]
+
+[reportOptions]
+minConfThreshold = 1
+
+[request]
+workDirectory = %q
+`, filepath.ToSlash(workDirectory))), "normal")
+	assert.NilError(t, err)
+
+	assert.StringsAreEqual(t, "org-base.yaml", cfg.Extends)
+	assert.StringsAreEqual(t, "http://localhost/", cfg.Context.Target)
+	assert.StringsAreEqual(t, ".*/signout.*;.*/logout.*;.*/admin.*", strings.Join(cfg.Context.ExcludeRegularExpressions, ";"))
+	assert.IntsAreEqual(t, 2, cfg.ReportOptions.MinRiskThreshold)
+	assert.IntsAreEqual(t, 1, cfg.ReportOptions.MinConfThreshold)
+	assert.StringsAreEqual(t, "localhost", strings.Join(cfg.Scope.AllowedHosts, ";"))
+}
+
+func TestParseConfigReplacesBaseLists(t *testing.T) {
+
+	workDirectory := t.TempDir()
+	basePath := writeInputFile(t, workDirectory, "org-base.toml", `
+[context]
+includeRegularExpressions = ["http://localhost/.*"]
+excludeRegularExpressions = [".*/logout.*"]
+`)
+
+	cfg, err := ParseConfigReader(strings.NewReader(fmt.Sprintf(`{
+  "extends": %q,
+  "replaceBaseLists": ["context.includeRegularExpressions"],
+  "context": {
+    "target": "http://localhost/app",
+    "includeRegularExpressions": ["http://localhost/app.*"],
+    "excludeRegularExpressions": [".*/delete.*"]
+  }
+}`, filepath.ToSlash(basePath))), "normal")
+	assert.NilError(t, err)
+
+	assert.StringsAreEqual(t, "http://localhost/app.*", strings.Join(cfg.Context.IncludeRegularExpressions, ";"))
+	assert.StringsAreEqual(t, ".*/logout.*;.*/delete.*", strings.Join(cfg.Context.ExcludeRegularExpressions, ";"))
+}
+
+func TestParseConfigRejectsBaseRequestFileCycle(t *testing.T) {
+
+	workDirectory := t.TempDir()
+	writeInputFile(t, workDirectory, "a.toml", `extends = "b.toml"`)
+	writeInputFile(t, workDirectory, "b.toml", `extends = "a.toml"`)
+
+	_, err := ParseConfigReader(strings.NewReader(fmt.Sprintf(`
+extends = "a.toml"
+
+[request]
+workDirectory = %q
+`, filepath.ToSlash(workDirectory))), "normal")
+	assert.NotNil(t, err)
+	assert.True(t, strings.Contains(err.Error(), "extends itself"))
+}
+
+func TestMergeSettings(t *testing.T) {
+
+	base := map[string]interface{}{
+		"contexts":       []interface{}{map[string]interface{}{"name": "Base"}},
+		"authentication": map[string]interface{}{"type": "formAuthentication", "logoutregularexpressions": []interface{}{".*/logout.*"}},
+	}
+	overlay := map[string]interface{}{
+		"contexts":       []interface{}{map[string]interface{}{"name": "App"}},
+		"Authentication": map[string]interface{}{"logoutRegularExpressions": []interface{}{".*/signout.*"}},
+	}
+
+	mergeSettings(base, overlay, "", map[string]bool{})
+
+	contexts := base["contexts"].([]interface{})
+	assert.IntsAreEqual(t, 1, len(contexts))
+	assert.StringsAreEqual(t, "App", contexts[0].(map[string]interface{})["name"].(string))
+
+	auth := base["Authentication"].(map[string]interface{})
+	assert.StringsAreEqual(t, "formAuthentication", auth["type"].(string))
+	assert.StringsAreEqual(t, ".*/signout.*", fmt.Sprint(auth["logoutRegularExpressions"].([]interface{})...))
+}
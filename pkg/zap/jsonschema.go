@@ -0,0 +1,277 @@
+package zap
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+const jsonSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// schemaField describes a request file key in the JSON Schema. Keys use the name of the Go type that declares the
+// field followed by the field's request file key (e.g., context.target).
+type schemaField struct {
+	description string
+	scanMode    string   // the only scan mode that accepts the key, when set
+	required    bool     // the key must be present when its section is present
+	enum        []string // the values that the key accepts, when set
+	minimum     *int
+	maximum     *int
+	apiMaxItems int  // the maximum number of list items in API scans, when set
+	apiRequired bool // the key must be present in API scans, which do not accept an alternative key
+}
+
+func schemaBound(n int) *int {
+	return &n
+}
+
+// schemaFields lists the description and constraints of every request file key. GenerateJSONSchema fails when a
+// Config field is missing from the list, so a new field must be described here.
+var schemaFields = map[string]schemaField{
+
+	"Config.schemaVersion":    {description: "The request file layout version; run \"zap migrate\" to upgrade an older file.", minimum: schemaBound(1), maximum: schemaBound(CurrentSchemaVersion)},
+	"Config.extends":          {description: "A base request file, absolute or relative to the input directory, whose settings this file overlays."},
+	"Config.replaceBaseLists": {description: "The key paths (e.g., context.excludeRegularExpressions) of regular expression lists that replace the base request file's lists instead of combining with them."},
+	"Config.request":          {description: "The request settings reserved for Code Dx use."},
+	"Config.contexts":         {description: "The contexts to scan in a single run, used instead of the top-level context and authentication sections.", apiMaxItems: 1},
+	"Config.reportOptions":    {description: "The ZAP report options."},
+	"Config.scanOptions":      {description: "The ZAP scan options."},
+	"Config.scope":            {description: "The hosts that ZAP may access."},
+
+	"request.name":           {description: "The name of the scan request."},
+	"request.secretsToMount": {description: "The names of the Kubernetes secrets mounted in the work directory."},
+	"request.workDirectory":  {description: "The directory storing secrets, trusted CA certificates, the analysis input, and the exit code file."},
+
+	"ContextConfig.context":              {description: "The ZAP context describing the scan target."},
+	"ContextConfig.authentication":       {description: "The ZAP context authentication settings."},
+	"ContextConfig.formAuthentication":   {description: "The form authentication settings, ignored when authentication.type is not formAuthentication."},
+	"ContextConfig.scriptAuthentication": {description: "The script authentication settings, ignored when authentication.type is not scriptAuthentication."},
+	"ContextConfig.headerAuthentication": {description: "The header authentication settings, ignored when authentication.type is not headerAuthentication."},
+	"ContextConfig.oauth2Authentication": {description: "The OAuth 2.0 authentication settings, ignored when authentication.type is not oauth2Authentication."},
+	"ContextConfig.alertFilters":         {description: "The alert filters that change the risk of known false positives."},
+	"ContextConfig.workflowSecrets":      {description: "The workflow secrets holding the context's credentials; all workflow secrets are used when the list is empty."},
+	"ContextConfig.credentialSources":    {description: "The sources of the context's credentials in addition to the workflow secrets."},
+
+	"credentialSources.secretFileNames":   {description: "The file names of each secret in a workflow secret directory."},
+	"credentialSources.file":              {description: "A JSON or YAML file, absolute or relative to the input directory, whose users list holds credentials."},
+	"credentialSources.environmentPrefix": {description: "The prefix of credential environment variables, such as APP_CREDENTIAL_ for APP_CREDENTIAL_ADMIN_PASSWORD."},
+
+	"secretFileNames.username":     {description: "The file name of the username secret; username is used if one is not provided."},
+	"secretFileNames.password":     {description: "The file name of the password secret; password is used if one is not provided."},
+	"secretFileNames.headerValue":  {description: "The file name of the authentication header value secret; header-value is used if one is not provided."},
+	"secretFileNames.clientId":     {description: "The file name of the OAuth2 client ID secret; client-id is used if one is not provided."},
+	"secretFileNames.clientSecret": {description: "The file name of the OAuth2 client secret; client-secret is used if one is not provided."},
+	"secretFileNames.refreshToken": {description: "The file name of the OAuth2 refresh token secret; refresh-token is used if one is not provided."},
+	"secretFileNames.totpSecret":   {description: "The file name of the TOTP secret; totp-secret is used if one is not provided. API scans reject credentials with a TOTP secret."},
+
+	"context.name":                                  {description: "The name of the ZAP context."},
+	"context.target":                                {description: "The URL where the scan starts, or the API definition for an API scan; a normal scan can list targets instead.", apiRequired: true},
+	"context.targets":                               {description: "The targets that one ZAP instance spiders and scans in turn, used instead of target and unsupported with contextFile.", scanMode: "normal"},
+	"context.format":                                {description: "The type of the API scan target.", scanMode: "api", required: true, enum: sortedSchemaValues(apiScanFormats)},
+	"context.openApiHostnameOverride":               {description: "The OpenAPI host override given to zap-api-scan.", scanMode: "api"},
+	"context.importURLs":                            {description: "The URLs to request before the spider runs.", scanMode: "normal"},
+	"context.includeRegularExpressions":             {description: "The regular expressions identifying URL patterns that are to be included."},
+	"context.excludeRegularExpressions":             {description: "The regular expressions identifying URL patterns that are to be excluded."},
+	"context.antiCrossSiteRequestForgeryTokenNames": {description: "The anti-XSRF token names used throughout the context."},
+	"context.includeTechnologies":                   {description: "The ZAP technologies (e.g., Db.PostgreSQL, Language.Java) to limit scan rules to."},
+	"context.excludeTechnologies":                   {description: "The ZAP technologies (e.g., Db.Oracle, Language.PHP) whose scan rules are skipped."},
+	"context.structuralParameters":                  {description: "The URL parameter names that identify distinct pages (e.g., page in /index.php?page=view)."},
+	"context.dataDrivenNodes":                       {description: "The regular expressions whose second group matches a data-driven path segment."},
+	"context.contextFile":                           {description: "An exported ZAP context file, either inline XML or a path relative to the input directory."},
+
+	"authentication.type":                     {description: "The authentication type.", enum: sortedSchemaValues(authenticationTypes)},
+	"authentication.loginIndicatorRegex":      {description: "The regular expression indicating a successful login request."},
+	"authentication.loggedOutIndicatorRegex":  {description: "The regular expression indicating a logged-out response."},
+	"authentication.forcedUserMode":           {description: "The decision to send every request as the context user (when true).", scanMode: "normal"},
+	"authentication.excludeLogoutLinks":       {description: "The decision to stop authenticated spiders from following logout links (when true).", scanMode: "normal"},
+	"authentication.logoutRegularExpressions": {description: "The logout link regular expressions; common logout and sign-out links are used if none are provided."},
+
+	"formAuthentication.formURL":                                   {description: "The URL of the login form."},
+	"formAuthentication.formUsernameFieldName":                     {description: "The login form's username field name."},
+	"formAuthentication.formPasswordFieldName":                     {description: "The login form's password field name."},
+	"formAuthentication.formAntiCrossSiteRequestForgeryFieldNames": {description: "The login form's anti-XSRF token field names."},
+	"formAuthentication.discoverAntiCrossSiteRequestForgeryFields": {description: "The decision to add the login form's hidden fields that look like anti-XSRF tokens (when true)."},
+	"formAuthentication.formPageURL":                               {description: "The URL of the page containing the login form; formURL is used if one is not provided."},
+	"formAuthentication.formExtraPostData":                         {description: "The extra data to include with the login request (e.g., otp={%totp%})."},
+
+	"scriptAuthentication.authenticationScriptContent":    {description: "The script for script authentication."},
+	"scriptAuthentication.authenticationScriptFile":       {description: "The path of a script file, absolute or relative to the input directory, to use instead of authenticationScriptContent."},
+	"scriptAuthentication.authenticationScriptEngine":     {description: "The script engine: zest, graaljs, python, or another ZAP script engine name."},
+	"scriptAuthentication.authenticationScriptParameters": {description: "The parameters passed to the authentication script in the order specified."},
+	"scriptAuthentication.usernameParameterName":          {description: "The name of the script's username credential parameter."},
+	"scriptAuthentication.passwordParameterName":          {description: "The name of the script's password credential parameter."},
+
+	"scriptParameter.name":  {description: "The name of the script parameter.", required: true},
+	"scriptParameter.value": {description: "The value of the script parameter."},
+
+	"headerAuthentication.authHeaderName": {description: "The name of the authentication header; Authorization is used if one is not provided."},
+	"headerAuthentication.authHeaderSite": {description: "The site that limits the inclusion of the authentication header."},
+
+	"oauth2Authentication.tokenURL":             {description: "The URL of the OAuth 2.0 token endpoint."},
+	"oauth2Authentication.scope":                {description: "The optional scope to request with the access token."},
+	"oauth2Authentication.authHeaderName":       {description: "The name of the authentication header; Authorization is used if one is not provided."},
+	"oauth2Authentication.authHeaderSite":       {description: "The site that limits the inclusion of the authentication header."},
+	"oauth2Authentication.refreshMarginSeconds": {description: "The number of seconds before token expiration to fetch a new token.", minimum: schemaBound(0)},
+
+	"target.name":                      {description: "The name of the target's ZAP context; the context name and the target's position are used if one is not provided."},
+	"target.url":                       {description: "The URL where the target's spider and scan start.", required: true},
+	"target.includeRegularExpressions": {description: "The regular expressions identifying URL patterns that are to be included, replacing the context's."},
+	"target.excludeRegularExpressions": {description: "The regular expressions identifying URL patterns that are to be excluded, replacing the context's."},
+
+	"alertFilter.ruleId":               {description: "The ID of the ZAP scan rule raising the alert.", required: true, minimum: schemaBound(1)},
+	"alertFilter.urlRegularExpression": {description: "The optional regular expression matching the alert URL."},
+	"alertFilter.parameter":            {description: "The optional alert parameter."},
+	"alertFilter.evidence":             {description: "The optional alert evidence."},
+	"alertFilter.newRisk":              {description: "The new risk of matching alerts.", required: true, enum: sortedSchemaValues(alertFilterRiskLevels)},
+
+	"reportOptions.minRiskThreshold": {description: "The minimum risk code (0 for informational through 3 for high) for ZAP report findings.", minimum: schemaBound(0), maximum: schemaBound(3)},
+	"reportOptions.minConfThreshold": {description: "The minimum confidence (0 for false positive through 4 for confirmed) for ZAP report findings.", minimum: schemaBound(0), maximum: schemaBound(4)},
+
+	"scanOptions.runActiveScan":        {description: "The decision to run an active scan (when true)."},
+	"scanOptions.mode":                 {description: "The ZAP mode set at startup; protected attacks only in-scope URLs, and safe, which passive API scans only accept, forbids attacks.", enum: sortedSchemaValues(zapModes)},
+	"scanOptions.apiScanOptions":       {description: "The CLI options passed to the zap-api-scan.py script.", scanMode: "api"},
+	"scanOptions.apiScanConfigContent": {description: "The content of an API scan rule config file.", scanMode: "api"},
+
+	"scope.allowedHosts": {description: "The hostnames to scan; *.example.com allows any subdomain of example.com."},
+	"scope.strict":       {description: "The decision to fail the run when ZAP accessed, or an API definition directs ZAP to access, a host outside of the allowlist (when true)."},
+}
+
+// sortedSchemaValues returns the non-empty keys of a map in order.
+func sortedSchemaValues[V any](m map[string]V) []string {
+	values := make([]string, 0, len(m))
+	for value := range m {
+		if value != "" {
+			values = append(values, value)
+		}
+	}
+	sort.Strings(values)
+	return values
+}
+
+// GenerateJSONSchema returns a JSON Schema describing the scan request file for a scan mode. Editors can use the
+// schema to validate and complete JSON and YAML request files.
+func GenerateJSONSchema(scanMode string) ([]byte, error) {
+
+	if !IsNormalScan(scanMode) && !IsApiScan(scanMode) {
+		return nil, fmt.Errorf("unknown scan mode %q; expected normal or api", scanMode)
+	}
+
+	schema, err := objectSchema(reflect.TypeOf(Config{}), scanMode)
+	if err != nil {
+		return nil, err
+	}
+	schema["$schema"] = jsonSchemaDraft
+	schema["title"] = fmt.Sprintf("ZAP runner %s scan request", scanMode)
+	schema["description"] = "A scan request file for the Code Dx ZAP runner. Request files can also use TOML, whose keys are not case sensitive."
+
+	b, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+func typeSchema(t reflect.Type, scanMode string) (map[string]interface{}, error) {
+
+	switch t.Kind() {
+	case reflect.Struct:
+		return objectSchema(t, scanMode)
+	case reflect.Slice:
+		items, err := typeSchema(t.Elem(), scanMode)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "array", "items": items}, nil
+	case reflect.Map:
+		return map[string]interface{}{"type": "object"}, nil
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}, nil
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}, nil
+	case reflect.Int:
+		return map[string]interface{}{"type": "integer"}, nil
+	}
+	return nil, fmt.Errorf("unable to describe type %s", t)
+}
+
+func objectSchema(t reflect.Type, scanMode string) (map[string]interface{}, error) {
+
+	properties := make(map[string]interface{})
+	required := make([]string, 0)
+	additionalProperties := false
+
+	if err := addSchemaProperties(t, scanMode, properties, &required, &additionalProperties); err != nil {
+		return nil, err
+	}
+
+	schema := map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": additionalProperties,
+	}
+	if len(required) > 0 {
+		sort.Strings(required)
+		schema["required"] = required
+	}
+	return schema, nil
+}
+
+// addSchemaProperties adds the exported fields of a struct, including the fields of squashed structs, that the scan
+// mode accepts. A struct with remaining keys, such as the request section, accepts additional properties.
+func addSchemaProperties(t reflect.Type, scanMode string, properties map[string]interface{}, required *[]string, additionalProperties *bool) error {
+
+	for i := 0; i < t.NumField(); i++ {
+
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		tag := field.Tag.Get("mapstructure")
+		if tag == ",squash" {
+			if err := addSchemaProperties(field.Type, scanMode, properties, required, additionalProperties); err != nil {
+				return err
+			}
+			continue
+		}
+		if tag == ",remain" {
+			*additionalProperties = true
+			continue
+		}
+
+		key := configKeyName(field.Name)
+		name := fmt.Sprintf("%s.%s", t.Name(), key)
+		sf, ok := schemaFields[name]
+		if !ok {
+			return fmt.Errorf("no JSON Schema description for %s", name)
+		}
+		if sf.scanMode != "" && sf.scanMode != scanMode {
+			continue
+		}
+
+		property, err := typeSchema(field.Type, scanMode)
+		if err != nil {
+			return err
+		}
+		property["description"] = sf.description
+		if len(sf.enum) > 0 {
+			property["enum"] = sf.enum
+		}
+		if sf.minimum != nil {
+			property["minimum"] = *sf.minimum
+		}
+		if sf.maximum != nil {
+			property["maximum"] = *sf.maximum
+		}
+		if sf.apiMaxItems > 0 && IsApiScan(scanMode) {
+			property["maxItems"] = sf.apiMaxItems
+		}
+
+		properties[key] = property
+		if sf.required || (sf.apiRequired && IsApiScan(scanMode)) {
+			*required = append(*required, key)
+		}
+	}
+	return nil
+}
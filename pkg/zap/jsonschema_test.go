@@ -0,0 +1,114 @@
+package zap
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/codedx/codedx-add-ins/pkg/assert"
+	"github.com/pelletier/go-toml/v2"
+)
+
+var jsonSchemaFiles = map[string]string{
+	"normal": "zap-schema.json",
+	"api":    "zap-api-scan-schema.json",
+}
+
+var exampleRequestFiles = map[string]string{
+	"normal": "zap-example.toml",
+	"api":    "zap-api-scan-example.toml",
+}
+
+func generateTestJSONSchema(t *testing.T, scanMode string) map[string]interface{} {
+
+	content, err := GenerateJSONSchema(scanMode)
+	assert.NilError(t, err)
+
+	schema := make(map[string]interface{})
+	assert.NilError(t, json.Unmarshal(content, &schema))
+	return schema
+}
+
+func schemaProperties(schema map[string]interface{}) map[string]interface{} {
+	if items, ok := schema["items"].(map[string]interface{}); ok {
+		schema = items
+	}
+	properties, _ := schema["properties"].(map[string]interface{})
+	return properties
+}
+
+func TestJSONSchemaFilesAreCurrent(t *testing.T) {
+
+	for scanMode, name := range jsonSchemaFiles {
+
+		content, err := GenerateJSONSchema(scanMode)
+		assert.NilError(t, err)
+
+		file, err := os.ReadFile(filepath.Join("..", "..", "build", "zap", name))
+		assert.NilError(t, err)
+
+		if string(file) != string(content) {
+			t.Errorf("build/zap/%s is out of date; run \"zap schema -scanMode %s -output build/zap/%s\"", name, scanMode, name)
+		}
+	}
+}
+
+func TestJSONSchemaDescribesExampleKeys(t *testing.T) {
+
+	for scanMode, name := range exampleRequestFiles {
+
+		content, err := os.ReadFile(filepath.Join("..", "..", "build", "zap", name))
+		assert.NilError(t, err)
+
+		settings := make(map[string]interface{})
+		assert.NilError(t, toml.Unmarshal(content, &settings))
+		delete(settings, "request")
+
+		var checkKeys func(prefix string, settings map[string]interface{}, properties map[string]interface{})
+		checkKeys = func(prefix string, settings map[string]interface{}, properties map[string]interface{}) {
+			for key, value := range settings {
+				property, ok := properties[key].(map[string]interface{})
+				if !ok {
+					t.Errorf("%s: %s%s is missing from the JSON Schema", name, prefix, key)
+					continue
+				}
+				if section, ok := value.(map[string]interface{}); ok {
+					checkKeys(prefix+key+".", section, schemaProperties(property))
+				}
+			}
+		}
+		checkKeys("", settings, schemaProperties(generateTestJSONSchema(t, scanMode)))
+	}
+}
+
+func TestJSONSchemaScanModeConstraints(t *testing.T) {
+
+	normal := schemaProperties(generateTestJSONSchema(t, "normal"))
+	api := schemaProperties(generateTestJSONSchema(t, "api"))
+
+	normalContext := schemaProperties(normal["context"].(map[string]interface{}))
+	apiContext := schemaProperties(api["context"].(map[string]interface{}))
+
+	_, ok := normalContext["format"]
+	assert.False(t, ok)
+	_, ok = apiContext["format"]
+	assert.True(t, ok)
+	_, ok = apiContext["importURLs"]
+	assert.False(t, ok)
+
+	_, ok = schemaProperties(normal["scanOptions"].(map[string]interface{}))["mode"]
+	assert.True(t, ok)
+	_, ok = schemaProperties(api["scanOptions"].(map[string]interface{}))["mode"]
+	assert.True(t, ok)
+
+	_, ok = normal["contexts"].(map[string]interface{})["maxItems"]
+	assert.False(t, ok)
+	assert.True(t, api["contexts"].(map[string]interface{})["maxItems"] == float64(1))
+
+	authenticationType := schemaProperties(normal["authentication"].(map[string]interface{}))["type"].(map[string]interface{})
+	assert.IntsAreEqual(t, 5, len(authenticationType["enum"].([]interface{})))
+
+	_, err := GenerateJSONSchema("unknown")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,224 @@
+package zap
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/zaproxy/zap-api-go/zap"
+)
+
+// defaultLogoutRegularExpressions match common logout and sign-out links, such as /logout, /account/sign-out?next=/,
+// logoff.php, and action=logout.
+var defaultLogoutRegularExpressions = []string{
+	"(?i).*[/=_.-](log|sign)[-_]?(out|off)([/?#._-].*)?$",
+}
+
+// LoggedOutWarningThreshold is the number of logged-out responses during a user's spider that indicate that the
+// spider ended the user's session.
+const LoggedOutWarningThreshold = 3
+
+// ExcludeLogoutLinks stops the spider from following links matching the logout regular expressions. The links stay in
+// the context, so responses reaching them by other means are still passively scanned. It returns a function that
+// restores the previous spider exclusions.
+func ExcludeLogoutLinks(zap *zap.Interface, cfg *ContextConfig) (func() error, error) {
+
+	if !cfg.Authentication.ExcludeLogoutLinks {
+		return func() error { return nil }, nil
+	}
+
+	result, err := (*zap).Spider().ExcludedFromScan()
+	if err != nil {
+		return nil, err
+	}
+	spiderExclusions, err := getZapStringListResult("excludedFromScan", result)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range cfg.Authentication.LogoutRegularExpressions {
+		if _, err := (*zap).Spider().ExcludeFromScan(e); err != nil {
+			return nil, err
+		}
+	}
+
+	return func() error {
+		if _, err := (*zap).Spider().ClearExcludedFromScan(); err != nil {
+			return err
+		}
+		for _, e := range spiderExclusions {
+			if _, err := (*zap).Spider().ExcludeFromScan(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, nil
+}
+
+// TrackLoggedOutResponses records the responses matching the context's logged-out indicator. It returns a function
+// that lists, in the order that ZAP received them, the matching responses received since the call, which is empty
+// when the context has no logged-out indicator.
+func TrackLoggedOutResponses(zap *zap.Interface, cfg *ContextConfig) (func() ([]LoggedOutResponse, error), error) {
+
+	regex := cfg.loggedOutIndicatorRegex()
+	if regex == "" {
+		return func() ([]LoggedOutResponse, error) { return nil, nil }, nil
+	}
+
+	previous, err := findLoggedOutResponses(zap, regex, cfg.Context.Target)
+	if err != nil {
+		return nil, err
+	}
+
+	return func() ([]LoggedOutResponse, error) {
+		current, err := findLoggedOutResponses(zap, regex, cfg.Context.Target)
+		if err != nil {
+			return nil, err
+		}
+
+		responses := make([]LoggedOutResponse, 0)
+		for _, response := range current {
+			if !containsLoggedOutResponse(previous, response.ID) {
+				responses = append(responses, response)
+			}
+		}
+		sort.Slice(responses, func(i, j int) bool {
+			return responses[i].ID < responses[j].ID
+		})
+		return responses, nil
+	}, nil
+}
+
+// loggedOutIndicatorRegex returns the logged-out indicator of the request file or, when the request file does not
+// specify one, of the user-supplied context file.
+func (c *ContextConfig) loggedOutIndicatorRegex() string {
+	if c.Authentication.LoggedOutIndicatorRegex != "" {
+		return c.Authentication.LoggedOutIndicatorRegex
+	}
+	return c.contextFileLoggedOutIndicator
+}
+
+// LoggedOutResponse is a ZAP history message whose response matched the logged-out indicator.
+type LoggedOutResponse struct {
+	ID  int
+	URL string
+}
+
+// loggedOutLinkSearchDepth is the number of messages before the first logged-out response that are searched for the
+// request that ended the session.
+const loggedOutLinkSearchDepth = 10
+
+// FindLoggedOutLink returns the URL of the last request that ZAP sent before the first logged-out response, which is
+// most likely the link that ended the session, or an empty string when there is no such request. The spider sends
+// requests concurrently, so the URL identifies the link only approximately.
+// It returns an error if a failure occurs.
+func FindLoggedOutLink(zap *zap.Interface, responses []LoggedOutResponse) (string, error) {
+
+	ids := precedingMessageIDs(responses)
+	if len(ids) == 0 {
+		return "", nil
+	}
+
+	result, err := (*zap).Core().MessagesById(strings.Join(ids, ","))
+	if err != nil {
+		return "", err
+	}
+	zapResult, err := getZapResult("messages", result)
+	if err != nil {
+		return "", err
+	}
+
+	link := ""
+	linkID := 0
+	list, _ := zapResult.([]interface{})
+	for _, v := range list {
+		fields, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		idValue, _ := fields["id"].(string)
+		id, err := strconv.Atoi(idValue)
+		if err != nil || id <= linkID {
+			continue
+		}
+		requestHeader, _ := fields["requestHeader"].(string)
+		if u := requestURL(requestHeader); u != "" {
+			link = u
+			linkID = id
+		}
+	}
+	return link, nil
+}
+
+// precedingMessageIDs returns the IDs of the messages before the first logged-out response that are not logged-out
+// responses themselves.
+func precedingMessageIDs(responses []LoggedOutResponse) []string {
+
+	if len(responses) == 0 {
+		return nil
+	}
+
+	ids := make([]string, 0, loggedOutLinkSearchDepth)
+	for id := responses[0].ID - 1; id > 0 && id >= responses[0].ID-loggedOutLinkSearchDepth; id-- {
+		found := false
+		for _, response := range responses {
+			if response.ID == id {
+				found = true
+				break
+			}
+		}
+		if !found {
+			ids = append(ids, strconv.Itoa(id))
+		}
+	}
+	return ids
+}
+
+// requestURL returns the URL in the request line of an HTTP request header.
+func requestURL(requestHeader string) string {
+	requestLine := strings.SplitN(requestHeader, "\n", 2)[0]
+	fields := strings.Fields(requestLine)
+	if len(fields) < 2 {
+		return ""
+	}
+	return fields[1]
+}
+
+func findLoggedOutResponses(zap *zap.Interface, regex string, target string) ([]LoggedOutResponse, error) {
+
+	result, err := (*zap).Search().UrlsByResponseRegex(regex, target, "", "")
+	if err != nil {
+		return nil, err
+	}
+
+	zapResult, err := getZapResult("urlsByResponseRegex", result)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]LoggedOutResponse, 0)
+	list, _ := zapResult.([]interface{})
+	for _, v := range list {
+		fields, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		idValue, _ := fields["id"].(string)
+		id, err := strconv.Atoi(idValue)
+		if err != nil {
+			continue
+		}
+		url, _ := fields["url"].(string)
+		responses = append(responses, LoggedOutResponse{ID: id, URL: url})
+	}
+	return responses, nil
+}
+
+func containsLoggedOutResponse(responses []LoggedOutResponse, id int) bool {
+	for _, response := range responses {
+		if response.ID == id {
+			return true
+		}
+	}
+	return false
+}
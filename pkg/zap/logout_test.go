@@ -0,0 +1,67 @@
+package zap
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/codedx/codedx-add-ins/pkg/assert"
+)
+
+func TestDefaultLogoutRegularExpressions(t *testing.T) {
+
+	logout := regexp.MustCompile(defaultLogoutRegularExpressions[0])
+
+	assert.True(t, logout.MatchString("https://localhost/logout"))
+	assert.True(t, logout.MatchString("https://localhost/account/Sign-Out?next=/"))
+	assert.True(t, logout.MatchString("https://localhost/logoff.php"))
+	assert.True(t, logout.MatchString("https://localhost/index.php?action=log_out"))
+
+	assert.False(t, logout.MatchString("https://localhost/catalog-offers"))
+	assert.False(t, logout.MatchString("https://localhost/login"))
+	assert.False(t, logout.MatchString("https://localhost/blog/outdoor"))
+}
+
+func TestApplyDefaultsLogoutRegularExpressions(t *testing.T) {
+
+	cfg := Config{}
+	cfg.Authentication.ExcludeLogoutLinks = true
+	applyDefaults(&cfg, "normal")
+	assert.IntsAreEqual(t, len(defaultLogoutRegularExpressions), len(cfg.Authentication.LogoutRegularExpressions))
+
+	cfg.Context.Name = "Context"
+	cfg.Context.Target = "http://localhost"
+	cfg.Context.Format = "openapi"
+	assert.False(t, cfg.IsValid("api"))
+}
+
+func TestContextFileLoggedOutIndicator(t *testing.T) {
+
+	cfg := Config{}
+	cfg.Context.ContextFile = `<?xml version="1.0" encoding="UTF-8" standalone="no"?>
+<configuration><context><name>Imported</name><authentication><type>2</type><loggedout>\Qlogin.php\E</loggedout></authentication></context></configuration>`
+
+	assert.NilError(t, loadContextFile(&cfg.ContextConfig, &cfg.Request))
+	assert.StringsAreEqual(t, `\Qlogin.php\E`, cfg.loggedOutIndicatorRegex())
+
+	cfg.Authentication.LoggedOutIndicatorRegex = "Sign in"
+	assert.StringsAreEqual(t, "Sign in", cfg.loggedOutIndicatorRegex())
+}
+
+func TestPrecedingMessageIDs(t *testing.T) {
+
+	assert.IntsAreEqual(t, 0, len(precedingMessageIDs(nil)))
+
+	responses := []LoggedOutResponse{{ID: 14, URL: "http://localhost/"}, {ID: 16, URL: "http://localhost/a"}}
+	assert.StringsAreEqual(t, "13,12,11,10,9,8,7,6,5,4", strings.Join(precedingMessageIDs(responses), ","))
+
+	responses = []LoggedOutResponse{{ID: 14, URL: "http://localhost/"}, {ID: 12, URL: "http://localhost/a"}}
+	assert.StringsAreEqual(t, "13,11,10,9,8,7,6,5,4", strings.Join(precedingMessageIDs(responses), ","))
+	assert.StringsAreEqual(t, "2,1", strings.Join(precedingMessageIDs([]LoggedOutResponse{{ID: 3}}), ","))
+}
+
+func TestRequestURL(t *testing.T) {
+
+	assert.StringsAreEqual(t, "http://localhost/logout.php", requestURL("GET http://localhost/logout.php HTTP/1.1\r\nHost: localhost\r\n\r\n"))
+	assert.StringsAreEqual(t, "", requestURL(""))
+}
@@ -0,0 +1,231 @@
+package zap
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zaproxy/zap-api-go/zap"
+)
+
+const defaultOAuth2RefreshMarginSeconds = 60
+
+// oauth2MinimumRetryWait is the shortest wait before retrying a failed token refresh.
+const oauth2MinimumRetryWait = 10 * time.Second
+
+// OAuth2Token holds an access token returned by an OAuth2 token endpoint.
+type OAuth2Token struct {
+	AccessToken  string
+	TokenType    string
+	RefreshToken string
+	ExpiresIn    time.Duration
+}
+
+type oauth2TokenResponse struct {
+	AccessToken      string      `json:"access_token"`
+	TokenType        string      `json:"token_type"`
+	RefreshToken     string      `json:"refresh_token"`
+	ExpiresIn        json.Number `json:"expires_in"`
+	Error            string      `json:"error"`
+	ErrorDescription string      `json:"error_description"`
+}
+
+// HeaderValue returns the authentication header value for the token.
+func (t OAuth2Token) HeaderValue() string {
+	tokenType := t.TokenType
+	if tokenType == "" || strings.EqualFold(tokenType, "bearer") {
+		tokenType = "Bearer"
+	}
+	return fmt.Sprintf("%s %s", tokenType, t.AccessToken)
+}
+
+// FetchOAuth2Token requests an access token from the configured token endpoint. It uses the refresh_token grant
+// when the credential includes a refresh token and the client_credentials grant otherwise.
+// It returns the token and an error if a failure occurs.
+func FetchOAuth2Token(cfg *ContextConfig, cred Credential) (OAuth2Token, error) {
+
+	var token OAuth2Token
+
+	form := url.Values{}
+	if cred.RefreshToken != "" {
+		form.Set("grant_type", "refresh_token")
+		form.Set("refresh_token", cred.RefreshToken)
+	} else {
+		form.Set("grant_type", "client_credentials")
+	}
+	if cred.Username != "" {
+		form.Set("client_id", cred.Username)
+	}
+	if cred.Password != "" {
+		form.Set("client_secret", cred.Password)
+	}
+	if cfg.OAuth2Authentication.Scope != "" {
+		form.Set("scope", cfg.OAuth2Authentication.Scope)
+	}
+
+	client := http.Client{Timeout: 30 * time.Second}
+	resp, err := client.PostForm(cfg.OAuth2Authentication.TokenURL, form)
+	if err != nil {
+		return token, err
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			log.Println(err)
+		}
+	}()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return token, err
+	}
+
+	var tokenResponse oauth2TokenResponse
+	if err := json.Unmarshal(body, &tokenResponse); err != nil {
+		return token, fmt.Errorf("unable to read token response with status code %d: %s", resp.StatusCode, err.Error())
+	}
+
+	if resp.StatusCode != http.StatusOK || tokenResponse.Error != "" {
+		errorStr := fmt.Sprintf("token request failed with status code %d", resp.StatusCode)
+		if tokenResponse.Error != "" {
+			errorStr += fmt.Sprintf(" and error '%s'", tokenResponse.Error)
+		}
+		if tokenResponse.ErrorDescription != "" {
+			errorStr += fmt.Sprintf(" (%s)", tokenResponse.ErrorDescription)
+		}
+		return token, errors.New(errorStr)
+	}
+
+	if tokenResponse.AccessToken == "" {
+		return token, errors.New("token response does not include an access token")
+	}
+
+	token.AccessToken = tokenResponse.AccessToken
+	token.TokenType = tokenResponse.TokenType
+	token.RefreshToken = tokenResponse.RefreshToken
+	if tokenResponse.ExpiresIn != "" {
+		expiresIn, err := tokenResponse.ExpiresIn.Int64()
+		if err != nil {
+			return token, fmt.Errorf("unable to read expires_in value '%s'", tokenResponse.ExpiresIn)
+		}
+		token.ExpiresIn = time.Duration(expiresIn) * time.Second
+	}
+
+	cfg.addTokenSecrets(token)
+	return token, nil
+}
+
+// ConfigureOAuth2Authentication fetches an OAuth2 access token and adds a ZAP replacer rule that includes it
+// with every request sent by the spider and active scan.
+// It returns the token and an error if a failure occurs.
+func ConfigureOAuth2Authentication(zap *zap.Interface, cfg *ContextConfig) (OAuth2Token, error) {
+
+	token, err := FetchOAuth2Token(cfg, cfg.GetCredentials()[0])
+	if err != nil {
+		return token, err
+	}
+	return token, setOAuth2AuthenticationHeader(zap, cfg, token)
+}
+
+// RefreshOAuth2Authentication fetches a new access token before the current one expires and updates the ZAP
+// replacer rule that includes it. It runs until a quit message arrives.
+func RefreshOAuth2Authentication(zap *zap.Interface, cfg *ContextConfig, token OAuth2Token, quit chan int, wg *sync.WaitGroup) {
+	refreshOAuth2Token(cfg, token, func(token OAuth2Token) error {
+		return setOAuth2AuthenticationHeader(zap, cfg, token)
+	}, quit, wg)
+}
+
+// WriteOAuth2TokenFile writes the authentication header value of a token to the file that auth_script_hook.py
+// reads during an API scan. The file is replaced, so the hook never reads a partial value.
+// It returns an error if a failure occurs.
+func WriteOAuth2TokenFile(path string, token OAuth2Token) error {
+
+	tempPath := path + ".tmp"
+	if err := ioutil.WriteFile(tempPath, []byte(token.HeaderValue()), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tempPath, path)
+}
+
+// RefreshOAuth2TokenFile fetches a new access token before the current one expires and writes it to the file that
+// auth_script_hook.py reads, so that the hook updates the authentication header in the API scan's ZAP instance. It
+// runs until a quit message arrives.
+func RefreshOAuth2TokenFile(cfg *ContextConfig, token OAuth2Token, path string, quit chan int, wg *sync.WaitGroup) {
+	refreshOAuth2Token(cfg, token, func(token OAuth2Token) error {
+		return WriteOAuth2TokenFile(path, token)
+	}, quit, wg)
+}
+
+// refreshOAuth2Token fetches a new access token before the current one expires and applies it. It runs until a
+// quit message arrives.
+func refreshOAuth2Token(cfg *ContextConfig, token OAuth2Token, apply func(OAuth2Token) error, quit chan int, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	cred := cfg.GetCredentials()[0]
+	wait := oauth2RefreshWait(token.ExpiresIn, cfg.OAuth2Authentication.RefreshMarginSeconds)
+	for {
+		if token.ExpiresIn <= 0 {
+			log.Println("OAuth2 token response does not include an expiration - the token will not be refreshed")
+			<-quit
+			return
+		}
+
+		select {
+		case <-quit:
+			return
+		case <-time.After(wait):
+		}
+
+		if token.RefreshToken != "" {
+			cred.RefreshToken = token.RefreshToken
+		}
+
+		log.Println("Refreshing OAuth2 token...")
+		newToken, err := FetchOAuth2Token(cfg, cred)
+		if err != nil {
+			wait = oauth2RetryWait(wait)
+			log.Printf("Unable to refresh OAuth2 token: %s - retrying in %s", err.Error(), wait)
+			continue
+		}
+		token = newToken
+		wait = oauth2RefreshWait(token.ExpiresIn, cfg.OAuth2Authentication.RefreshMarginSeconds)
+
+		if err := apply(token); err != nil {
+			log.Printf("Unable to update OAuth2 authentication header: %s", err.Error())
+			continue
+		}
+		log.Println("OAuth2 token refreshed")
+	}
+}
+
+// oauth2RetryWait returns the wait before retrying a failed token refresh, which halves the previous wait without
+// going below oauth2MinimumRetryWait, so that retries continue after the token expires.
+func oauth2RetryWait(previousWait time.Duration) time.Duration {
+	wait := previousWait / 2
+	if wait < oauth2MinimumRetryWait {
+		wait = oauth2MinimumRetryWait
+	}
+	return wait
+}
+
+func oauth2RefreshWait(expiresIn time.Duration, refreshMarginSeconds int) time.Duration {
+	if refreshMarginSeconds <= 0 {
+		refreshMarginSeconds = defaultOAuth2RefreshMarginSeconds
+	}
+	wait := expiresIn - time.Duration(refreshMarginSeconds)*time.Second
+	if wait < expiresIn/2 {
+		wait = expiresIn / 2
+	}
+	return wait
+}
+
+func setOAuth2AuthenticationHeader(zap *zap.Interface, cfg *ContextConfig, token OAuth2Token) error {
+	return setAuthenticationHeader(zap, cfg.resourceName("authHeader"), cfg.OAuth2Authentication.AuthHeaderName, cfg.OAuth2Authentication.AuthHeaderSite, token.HeaderValue())
+}
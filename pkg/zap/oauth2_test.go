@@ -0,0 +1,129 @@
+package zap
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/codedx/codedx-add-ins/pkg/assert"
+)
+
+func newTokenServer(t *testing.T) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Fatal(err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+
+		switch r.PostForm.Get("grant_type") {
+		case "client_credentials":
+			if r.PostForm.Get("client_id") != "id" || r.PostForm.Get("client_secret") != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				_, _ = fmt.Fprint(w, `{"error":"invalid_client"}`)
+				return
+			}
+			_, _ = fmt.Fprintf(w, `{"access_token":"cc-%s","token_type":"bearer","expires_in":300}`, r.PostForm.Get("scope"))
+		case "refresh_token":
+			_, _ = fmt.Fprintf(w, `{"access_token":"rt-%s","token_type":"Bearer","refresh_token":"next","expires_in":"120"}`, r.PostForm.Get("refresh_token"))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = fmt.Fprint(w, `{"error":"unsupported_grant_type"}`)
+		}
+	}))
+}
+
+func TestFetchOAuth2TokenClientCredentials(t *testing.T) {
+
+	server := newTokenServer(t)
+	defer server.Close()
+
+	cfg := Config{}
+	cfg.OAuth2Authentication.TokenURL = server.URL
+	cfg.OAuth2Authentication.Scope = "api"
+
+	token, err := FetchOAuth2Token(&cfg.ContextConfig, Credential{Username: "id", Password: "secret"})
+	assert.NilError(t, err)
+	assert.StringsAreEqual(t, "Bearer cc-api", token.HeaderValue())
+	assert.Int64sAreEqual(t, int64(300*time.Second), int64(token.ExpiresIn))
+}
+
+func TestFetchOAuth2TokenRefreshToken(t *testing.T) {
+
+	server := newTokenServer(t)
+	defer server.Close()
+
+	cfg := Config{}
+	cfg.OAuth2Authentication.TokenURL = server.URL
+
+	token, err := FetchOAuth2Token(&cfg.ContextConfig, Credential{RefreshToken: "first"})
+	assert.NilError(t, err)
+	assert.StringsAreEqual(t, "Bearer rt-first", token.HeaderValue())
+	assert.StringsAreEqual(t, "next", token.RefreshToken)
+	assert.Int64sAreEqual(t, int64(120*time.Second), int64(token.ExpiresIn))
+}
+
+func TestFetchOAuth2TokenInvalidClient(t *testing.T) {
+
+	server := newTokenServer(t)
+	defer server.Close()
+
+	cfg := Config{}
+	cfg.OAuth2Authentication.TokenURL = server.URL
+
+	_, err := FetchOAuth2Token(&cfg.ContextConfig, Credential{Username: "id", Password: "wrong"})
+	assert.NotNil(t, err)
+	assert.StringContains(t, "invalid_client", err.Error())
+}
+
+func TestOAuth2RefreshWait(t *testing.T) {
+
+	assert.Int64sAreEqual(t, int64(240*time.Second), int64(oauth2RefreshWait(300*time.Second, 0)))
+	assert.Int64sAreEqual(t, int64(30*time.Second), int64(oauth2RefreshWait(60*time.Second, 0)))
+	assert.Int64sAreEqual(t, int64(290*time.Second), int64(oauth2RefreshWait(300*time.Second, 10)))
+}
+
+func TestOAuth2RetryWait(t *testing.T) {
+
+	assert.Int64sAreEqual(t, int64(120*time.Second), int64(oauth2RetryWait(240*time.Second)))
+	assert.Int64sAreEqual(t, int64(oauth2MinimumRetryWait), int64(oauth2RetryWait(15*time.Second)))
+	assert.Int64sAreEqual(t, int64(oauth2MinimumRetryWait), int64(oauth2RetryWait(0)))
+}
+
+func TestRefreshOAuth2TokenFile(t *testing.T) {
+
+	server := newTokenServer(t)
+	defer server.Close()
+
+	cfg := Config{}
+	cfg.Authentication.Type = "oauth2Authentication"
+	cfg.OAuth2Authentication.TokenURL = server.URL
+	cfg.credentials = Credentials{{Username: "id", Password: "secret"}}
+
+	tokenFile := filepath.Join(t.TempDir(), ApiScanOAuth2TokenFileName)
+	assert.NilError(t, WriteOAuth2TokenFile(tokenFile, OAuth2Token{AccessToken: "first", ExpiresIn: 2 * time.Second}))
+
+	content, err := os.ReadFile(tokenFile)
+	assert.NilError(t, err)
+	assert.StringsAreEqual(t, "Bearer first", string(content))
+
+	var wg sync.WaitGroup
+	quit := make(chan int)
+	wg.Add(1)
+	go RefreshOAuth2TokenFile(&cfg.ContextConfig, OAuth2Token{AccessToken: "first", ExpiresIn: 2 * time.Second}, tokenFile, quit, &wg)
+
+	// the token refreshes after half of its two-second lifetime
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(100 * time.Millisecond) {
+		if content, err = os.ReadFile(tokenFile); err == nil && string(content) != "Bearer first" {
+			break
+		}
+	}
+	close(quit)
+	wg.Wait()
+
+	assert.StringsAreEqual(t, "Bearer cc-", string(content))
+}
@@ -0,0 +1,59 @@
+package zap
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ConfigOverrideEnvironmentPrefix is the prefix of environment variables that override request file keys. The rest
+// of the variable name is the key path with underscores in place of periods (e.g., ZAPRUNNER_CONTEXT_TARGET).
+const ConfigOverrideEnvironmentPrefix = "ZAPRUNNER_"
+
+// ConfigOverride replaces the value at a key path of the request file, such as context.target.
+type ConfigOverride struct {
+	Key   string
+	Value string
+}
+
+// ParseConfigOverride reads a key=value override. A value that is a JSON array or object, such as
+// ["https://localhost/.*"], replaces a list or section.
+func ParseConfigOverride(override string) (ConfigOverride, error) {
+
+	keyValue := strings.SplitN(override, "=", 2)
+	key := strings.TrimSpace(keyValue[0])
+	if len(keyValue) != 2 || key == "" {
+		return ConfigOverride{}, fmt.Errorf("unable to read override %q; expected key=value", override)
+	}
+	return ConfigOverride{Key: key, Value: keyValue[1]}, nil
+}
+
+// EnvironmentConfigOverrides returns an override for each environment variable, in the format of os.Environ, that
+// begins with ConfigOverrideEnvironmentPrefix.
+func EnvironmentConfigOverrides(environment []string) []ConfigOverride {
+
+	overrides := make([]ConfigOverride, 0)
+	for _, variable := range environment {
+
+		nameValue := strings.SplitN(variable, "=", 2)
+		if len(nameValue) != 2 || !strings.HasPrefix(nameValue[0], ConfigOverrideEnvironmentPrefix) {
+			continue
+		}
+
+		key := strings.ToLower(strings.TrimPrefix(nameValue[0], ConfigOverrideEnvironmentPrefix))
+		overrides = append(overrides, ConfigOverride{Key: strings.ReplaceAll(key, "_", "."), Value: nameValue[1]})
+	}
+	return overrides
+}
+
+func (o ConfigOverride) value() interface{} {
+
+	trimmedValue := strings.TrimSpace(o.Value)
+	if strings.HasPrefix(trimmedValue, "[") || strings.HasPrefix(trimmedValue, "{") {
+		var value interface{}
+		if err := json.Unmarshal([]byte(trimmedValue), &value); err == nil {
+			return value
+		}
+	}
+	return o.Value
+}
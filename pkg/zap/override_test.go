@@ -0,0 +1,59 @@
+package zap
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/codedx/codedx-add-ins/pkg/assert"
+)
+
+func TestParseConfigOverride(t *testing.T) {
+
+	override, err := ParseConfigOverride("context.target=https://localhost/?a=b")
+	assert.NilError(t, err)
+	assert.StringsAreEqual(t, "context.target", override.Key)
+	assert.StringsAreEqual(t, "https://localhost/?a=b", override.Value)
+
+	_, err = ParseConfigOverride("context.target")
+	assert.NotNil(t, err)
+}
+
+func TestEnvironmentConfigOverrides(t *testing.T) {
+
+	overrides := EnvironmentConfigOverrides([]string{
+		"PATH=/usr/bin",
+		"ZAPRUNNER_CONTEXT_TARGET=https://localhost",
+		"ZAPRUNNER_REPORTOPTIONS_MINRISKTHRESHOLD=2",
+	})
+	assert.IntsAreEqual(t, 2, len(overrides))
+	assert.StringsAreEqual(t, "context.target", overrides[0].Key)
+	assert.StringsAreEqual(t, "reportoptions.minriskthreshold", overrides[1].Key)
+}
+
+func TestParseConfigReaderAppliesOverrides(t *testing.T) {
+
+	config := `
+[context]
+target = "http://localhost/"
+
+[request]
+workDirectory = "` + filepath.ToSlash(t.TempDir()) + `"
+`
+	overrides := append(EnvironmentConfigOverrides([]string{"ZAPRUNNER_CONTEXT_TARGET=https://staging"}),
+		ConfigOverride{Key: "context.target", Value: "https://test"},
+		ConfigOverride{Key: "context.excludeRegularExpressions", Value: `["https://test/logout.*"]`},
+		ConfigOverride{Key: "scanOptions.runActiveScan", Value: "true"},
+		ConfigOverride{Key: "reportOptions.minRiskThreshold", Value: "2"})
+
+	cfg, err := ParseConfigReader(strings.NewReader(config), "normal", overrides...)
+	assert.NilError(t, err)
+	assert.StringsAreEqual(t, "https://test", cfg.Context.Target)
+	assert.StringsAreEqual(t, "https://test.*", cfg.Context.IncludeRegularExpressions[0])
+	assert.StringsAreEqual(t, "https://test/logout.*", cfg.Context.ExcludeRegularExpressions[0])
+	assert.True(t, cfg.ScanOptions.RunActiveScan)
+	assert.IntsAreEqual(t, 2, cfg.ReportOptions.MinRiskThreshold)
+
+	_, err = ParseConfigReader(strings.NewReader(config), "normal", ConfigOverride{Key: "context.tagret", Value: "https://test"})
+	assert.NotNil(t, err)
+}
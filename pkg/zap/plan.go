@@ -0,0 +1,292 @@
+package zap
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Plan describes the work that a scan request would do without starting ZAP. It includes no secret values.
+type Plan struct {
+	ScanMode         string        `json:"scanMode"`
+	ZapMode          string        `json:"zapMode,omitempty"`
+	AllowedHosts     []string      `json:"allowedHosts,omitempty"`
+	ZapOptions       []string      `json:"zapOptions,omitempty"`
+	Contexts         []ContextPlan `json:"contexts"`
+	Phases           []string      `json:"phases"`
+	ApiScan          *ApiScanPlan  `json:"apiScan,omitempty"`
+	MinRiskThreshold int           `json:"minRiskThreshold"`
+	MinConfThreshold int           `json:"minConfThreshold"`
+}
+
+// ContextPlan describes a ZAP context and the users that scan it.
+type ContextPlan struct {
+	Name                      string     `json:"name"`
+	Target                    string     `json:"target"`
+	Format                    string     `json:"format,omitempty"`
+	ContextFile               bool       `json:"contextFile"`
+	IncludeRegularExpressions []string   `json:"includeRegularExpressions"`
+	ExcludeRegularExpressions []string   `json:"excludeRegularExpressions"`
+	Authentication            string     `json:"authentication"`
+	Users                     []UserPlan `json:"users"`
+}
+
+// UserPlan describes a ZAP user without its credential values.
+type UserPlan struct {
+	Username  string   `json:"username"`
+	Role      string   `json:"role,omitempty"`
+	StartURLs []string `json:"startURLs,omitempty"`
+	TOTP      bool     `json:"totp"`
+}
+
+// ApiScanPlan describes the zap-api-scan.py command and the files in the ZAP working directory that it uses.
+type ApiScanPlan struct {
+	Command     []string      `json:"command"`
+	Environment []string      `json:"environment"`
+	Files       []ApiScanFile `json:"files"`
+}
+
+// NewPlan returns the plan for a valid configuration. An API scan plan uses the specified zap-api-scan.py path and
+// ZAP working directory.
+func NewPlan(config *Config, scanMode string, zapApiScanPath string, zapWorkDir string) *Plan {
+
+	plan := &Plan{
+		ScanMode:         scanMode,
+		AllowedHosts:     config.Scope.AllowedHosts,
+		ZapOptions:       config.Scope.ZapConfigOptions(),
+		Contexts:         make([]ContextPlan, 0),
+		Phases:           make([]string, 0),
+		MinRiskThreshold: config.ReportOptions.MinRiskThreshold,
+		MinConfThreshold: config.ReportOptions.MinConfThreshold,
+	}
+
+	for _, contextConfig := range config.GetContexts() {
+		plan.Contexts = append(plan.Contexts, newContextPlan(contextConfig, scanMode))
+	}
+
+	if IsApiScan(scanMode) {
+		contextConfig := config.GetContexts()[0]
+		plan.ApiScan = &ApiScanPlan{
+			Command:     append([]string{"python3"}, ApiScanArguments(config, zapApiScanPath, zapWorkDir)...),
+			Environment: ApiScanEnvironment(contextConfig, redactedValue, zapWorkDir),
+			Files:       ApiScanFiles(config, zapWorkDir),
+		}
+		plan.Phases = apiScanPhases(config, contextConfig)
+		return plan
+	}
+
+	plan.ZapMode = config.ScanOptions.Mode
+	plan.Phases = normalScanPhases(config)
+	return plan
+}
+
+func newContextPlan(contextConfig *ContextConfig, scanMode string) ContextPlan {
+
+	contextPlan := ContextPlan{
+		Name:                      contextConfig.Context.Name,
+		Target:                    contextConfig.Context.Target,
+		Format:                    contextConfig.Context.Format,
+		ContextFile:               contextConfig.UseContextFile(),
+		IncludeRegularExpressions: contextConfig.Context.IncludeRegularExpressions,
+		ExcludeRegularExpressions: contextConfig.Context.ExcludeRegularExpressions,
+		Authentication:            "none",
+		Users:                     make([]UserPlan, 0),
+	}
+	if contextPlan.IncludeRegularExpressions == nil {
+		contextPlan.IncludeRegularExpressions = make([]string, 0)
+	}
+	if contextPlan.ExcludeRegularExpressions == nil {
+		contextPlan.ExcludeRegularExpressions = make([]string, 0)
+	}
+
+	if !contextConfig.IsAuthenticationEnabled() {
+		return contextPlan
+	}
+	contextPlan.Authentication = contextConfig.Authentication.Type
+
+	for _, cred := range contextConfig.GetCredentials() {
+		userPlan := UserPlan{Username: cred.Username, Role: cred.Metadata.Role, TOTP: cred.TotpSecret != ""}
+		if IsNormalScan(scanMode) {
+			user := User{Credential: cred}
+			userPlan.StartURLs = user.GetStartURLs(contextConfig.Context.Target)
+		}
+		contextPlan.Users = append(contextPlan.Users, userPlan)
+	}
+	return contextPlan
+}
+
+// normalScanPhases lists the steps of a normal scan in the order that they run.
+func normalScanPhases(config *Config) []string {
+
+	phases := []string{"Start ZAP"}
+	if config.ScanOptions.Mode != "" {
+		phases = append(phases, fmt.Sprintf("Set ZAP mode to %s", config.ScanOptions.Mode))
+	}
+
+	contextConfigs := config.GetContexts()
+	for _, contextConfig := range contextConfigs {
+		phases = append(phases, fmt.Sprintf("Create context %s", contextConfig.Context.Name))
+		if len(contextConfig.Context.ImportURLs) > 0 {
+			phases = append(phases, fmt.Sprintf("Import %d URL(s) into context %s", len(contextConfig.Context.ImportURLs), contextConfig.Context.Name))
+		}
+	}
+
+	for _, contextConfig := range contextConfigs {
+
+		name := contextConfig.Context.Name
+		if contextConfig.IsAuthenticationEnabled() {
+			if contextConfig.UseHeaderAuthentication() {
+				phases = append(phases, fmt.Sprintf("Add the authentication header for context %s", name))
+			}
+			if contextConfig.UseOAuth2Authentication() {
+				phases = append(phases, fmt.Sprintf("Fetch an OAuth2 token from %s and refresh it during the scan of context %s", contextConfig.OAuth2Authentication.TokenURL, name))
+			}
+		}
+
+		phases = append(phases, fmt.Sprintf("Spider context %s (anonymous) at %s", name, contextConfig.Context.Target))
+		if config.ScanOptions.RunActiveScan {
+			phases = append(phases, fmt.Sprintf("Scan context %s (anonymous) at %s", name, contextConfig.Context.Target))
+		}
+
+		if contextConfig.Authentication.ExcludeLogoutLinks && len(contextConfig.GetCredentials()) > 0 {
+			phases = append(phases, fmt.Sprintf("Exclude logout links from the spiders of context %s", name))
+		}
+
+		for _, cred := range contextConfig.GetCredentials() {
+			user := User{Credential: cred}
+			for _, startURL := range user.GetStartURLs(contextConfig.Context.Target) {
+				phases = append(phases, fmt.Sprintf("Spider context %s (%s) at %s", name, cred.Username, startURL))
+			}
+			if config.ScanOptions.RunActiveScan {
+				for _, startURL := range user.GetStartURLs(contextConfig.Context.Target) {
+					phases = append(phases, fmt.Sprintf("Scan context %s (%s) at %s", name, cred.Username, startURL))
+				}
+			}
+			if cred.Metadata.Role != "" {
+				phases = append(phases, fmt.Sprintf("Label alerts from %s with role %s", cred.Username, cred.Metadata.Role))
+			}
+		}
+	}
+
+	if config.Scope.IsEnabled() {
+		phase := "Check for hosts outside of scope.allowedHosts"
+		if config.Scope.Strict {
+			phase += " and fail when there are any"
+		}
+		phases = append(phases, phase)
+	}
+
+	return append(phases, "Save report", "Stop ZAP")
+}
+
+// apiScanPhases lists the steps of an API scan in the order that they run.
+func apiScanPhases(config *Config, contextConfig *ContextConfig) []string {
+
+	phases := make([]string, 0)
+	if config.Scope.IsEnabled() {
+		phase := "Check the API definition for hosts outside of scope.allowedHosts"
+		if config.Scope.Strict {
+			phase += " and fail when there are any"
+		}
+		phases = append(phases, phase)
+	}
+	if config.activeScanAllowlist != nil && config.ScanOptions.RunActiveScan && contextConfig.isApiDefinitionFile() && contextConfig.Context.OpenApiHostnameOverride == "" {
+		phases = append(phases, "Check the API definition's server hosts against the active scan target allowlist")
+	}
+	if contextConfig.IsContextFileRequired() {
+		if !contextConfig.IsContextExportRequired() {
+			phases = append(phases, fmt.Sprintf("Write context %s from context.contextFile", contextConfig.Context.Name))
+		} else if contextConfig.UseContextFile() {
+			phases = append(phases, fmt.Sprintf("Start a temporary ZAP instance to import context.contextFile, apply the credentials and settings, and export context %s", contextConfig.Context.Name))
+		} else {
+			phases = append(phases, fmt.Sprintf("Start a temporary ZAP instance to export context %s", contextConfig.Context.Name))
+		}
+	}
+	if config.ScanOptions.ApiScanConfigContent != "" {
+		phases = append(phases, "Write the API scan rule config file")
+	}
+	if contextConfig.IsAuthenticationEnabled() && contextConfig.UseOAuth2Authentication() {
+		phases = append(phases, fmt.Sprintf("Fetch an OAuth2 token from %s and refresh it during the scan through auth_script_hook.py", contextConfig.OAuth2Authentication.TokenURL))
+	}
+	return append(phases, "Run zap-api-scan.py", "Copy and apply the template to the report")
+}
+
+// JSON returns the plan as an indented JSON document.
+func (p *Plan) JSON() (string, error) {
+	b, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// String returns the plan as text.
+func (p *Plan) String() string {
+
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Scan mode: %s\n", p.ScanMode)
+	if p.ZapMode != "" {
+		fmt.Fprintf(&b, "ZAP mode: %s\n", p.ZapMode)
+	}
+	if len(p.AllowedHosts) > 0 {
+		fmt.Fprintf(&b, "Allowed hosts: %s\n", strings.Join(p.AllowedHosts, ", "))
+	}
+	fmt.Fprintf(&b, "Report thresholds: minimum risk %d, minimum confidence %d\n", p.MinRiskThreshold, p.MinConfThreshold)
+
+	for _, ctx := range p.Contexts {
+		fmt.Fprintf(&b, "\nContext %s\n", ctx.Name)
+		fmt.Fprintf(&b, "  Target: %s\n", ctx.Target)
+		if ctx.Format != "" {
+			fmt.Fprintf(&b, "  Format: %s\n", ctx.Format)
+		}
+		if ctx.ContextFile {
+			b.WriteString("  Context file: yes\n")
+		}
+		writePlanList(&b, "  ", "Include regular expressions", ctx.IncludeRegularExpressions)
+		writePlanList(&b, "  ", "Exclude regular expressions", ctx.ExcludeRegularExpressions)
+		fmt.Fprintf(&b, "  Authentication: %s\n", ctx.Authentication)
+		for _, user := range ctx.Users {
+			fmt.Fprintf(&b, "  User %s", user.Username)
+			if user.Role != "" {
+				fmt.Fprintf(&b, " (role %s)", user.Role)
+			}
+			if user.TOTP {
+				b.WriteString(" with TOTP")
+			}
+			b.WriteString("\n")
+			writePlanList(&b, "    ", "Start URLs", user.StartURLs)
+		}
+	}
+
+	b.WriteString("\nPhases\n")
+	for i, phase := range p.Phases {
+		fmt.Fprintf(&b, "  %d. %s\n", i+1, phase)
+	}
+
+	if p.ApiScan != nil {
+		b.WriteString("\nzap-api-scan command\n")
+		for _, arg := range p.ApiScan.Command {
+			fmt.Fprintf(&b, "  %s\n", arg)
+		}
+		if len(p.ApiScan.Environment) > 0 {
+			b.WriteString("\n")
+			writePlanList(&b, "", "zap-api-scan environment", p.ApiScan.Environment)
+		}
+		b.WriteString("\nFiles\n")
+		for _, file := range p.ApiScan.Files {
+			fmt.Fprintf(&b, "  %s - %s\n", file.Path, file.Description)
+		}
+	}
+	return b.String()
+}
+
+func writePlanList(b *strings.Builder, indent string, title string, values []string) {
+	if len(values) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "%s%s\n", indent, title)
+	for _, value := range values {
+		fmt.Fprintf(b, "%s  %s\n", indent, value)
+	}
+}
@@ -0,0 +1,151 @@
+package zap
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/codedx/codedx-add-ins/pkg/assert"
+)
+
+func TestNewPlanNormalScan(t *testing.T) {
+
+	cfg := Config{}
+	cfg.Context.Name = "Context"
+	cfg.Context.Target = "http://localhost/"
+	cfg.Authentication.Type = "formAuthentication"
+	cfg.ScanOptions.RunActiveScan = true
+	cfg.ScanOptions.Mode = "protected"
+	cfg.credentials = Credentials{
+		{Username: "admin", Password: "s3cret", TotpSecret: "JBSWY3DPEHPK3PXP"},
+		{Username: "reader", Password: "s3cret", Metadata: UserMetadata{Role: "read-only", StartURLs: []string{"http://localhost/reports"}}},
+	}
+
+	plan := NewPlan(&cfg, "normal", "/zap/zap-api-scan.py", "/zap/wrk")
+
+	assert.StringsAreEqual(t, "protected", plan.ZapMode)
+	assert.True(t, plan.ApiScan == nil)
+	assert.IntsAreEqual(t, 1, len(plan.Contexts))
+	assert.StringsAreEqual(t, "formAuthentication", plan.Contexts[0].Authentication)
+	assert.IntsAreEqual(t, 2, len(plan.Contexts[0].Users))
+	assert.True(t, plan.Contexts[0].Users[0].TOTP)
+	assert.StringsAreEqual(t, "http://localhost/reports", strings.Join(plan.Contexts[0].Users[1].StartURLs, ";"))
+
+	phases := strings.Join(plan.Phases, "\n")
+	assert.True(t, strings.Contains(phases, "Set ZAP mode to protected"))
+	assert.True(t, strings.Contains(phases, "Scan context Context (anonymous) at http://localhost/"))
+	assert.True(t, strings.Contains(phases, "Spider context Context (reader) at http://localhost/reports"))
+	assert.True(t, strings.Contains(phases, "Label alerts from reader with role read-only"))
+
+	planJSON, err := plan.JSON()
+	assert.NilError(t, err)
+	assert.False(t, strings.Contains(planJSON, "s3cret"))
+	assert.False(t, strings.Contains(planJSON, "JBSWY3DPEHPK3PXP"))
+	assert.False(t, strings.Contains(plan.String(), "s3cret"))
+}
+
+func TestNewPlanApiScan(t *testing.T) {
+
+	cfg := Config{}
+	cfg.Context.Name = "Context"
+	cfg.Context.Target = "openapi.json"
+	cfg.Context.Format = "openapi"
+	cfg.Context.OpenApiHostnameOverride = "localhost:8080"
+	cfg.Authentication.Type = "headerAuthentication"
+	cfg.HeaderAuthentication.AuthHeaderName = "X-Api-Key"
+	cfg.ScanOptions.ApiScanConfigContent = "10020\tIGNORE\t(Anti-clickjacking Header)"
+	cfg.ScanOptions.ApiScanOptions = []string{"-d"}
+	cfg.credentials = Credentials{{Password: "s3cret"}}
+
+	plan := NewPlan(&cfg, "api", "/zap/zap-api-scan.py", "/zap/wrk")
+
+	assert.StringsAreEqual(t, "python3 /zap/zap-api-scan.py -t openapi.json -f openapi -x report.xml -O localhost:8080 -S -c config.txt -d",
+		strings.Join(plan.ApiScan.Command, " "))
+	assert.StringsAreEqual(t, "ZAP_AUTH_HEADER_VALUE=[REDACTED];ZAP_AUTH_HEADER=X-Api-Key", strings.Join(plan.ApiScan.Environment, ";"))
+	assert.IntsAreEqual(t, 2, len(plan.ApiScan.Files))
+	assert.True(t, strings.HasSuffix(plan.ApiScan.Files[0].Path, "config.txt"))
+
+	planJSON, err := plan.JSON()
+	assert.NilError(t, err)
+	assert.False(t, strings.Contains(planJSON, "s3cret"))
+}
+
+func TestApiScanArgumentsWithMode(t *testing.T) {
+
+	cfg := Config{}
+	cfg.Context.Target = "openapi.json"
+	cfg.Context.Format = "openapi"
+	cfg.ScanOptions.Mode = "safe"
+
+	args := ApiScanArguments(&cfg, "zap-api-scan.py", "wrk")
+	assert.StringsAreEqual(t, "-z", args[len(args)-2])
+	assert.StringsAreEqual(t, "-config view.mode=safe", args[len(args)-1])
+
+	cfg.Scope.AllowedHosts = []string{"localhost"}
+	args = ApiScanArguments(&cfg, "zap-api-scan.py", "wrk")
+	assert.StringsAreEqual(t, "-z", args[len(args)-2])
+	assert.StringsAreEqual(t, `-config globalexcludeurl.url_list.url(100).regex=(?i)^https?://(?!(localhost)(:\d+)?([/?#].*)?$).*$`+
+		" -config globalexcludeurl.url_list.url(100).description=out-of-scope-hosts"+
+		" -config globalexcludeurl.url_list.url(100).enabled=true"+
+		" -config view.mode=safe", args[len(args)-1])
+}
+
+func TestApiScanWithOAuth2Authentication(t *testing.T) {
+
+	cfg := Config{}
+	cfg.Context.Target = "openapi.json"
+	cfg.Context.Format = "openapi"
+	cfg.Authentication.Type = "oauth2Authentication"
+	cfg.OAuth2Authentication.TokenURL = "https://login.localhost/token"
+	cfg.OAuth2Authentication.AuthHeaderSite = "api.localhost"
+	cfg.credentials = Credentials{{Username: "id", Password: "secret"}}
+
+	args := strings.Join(ApiScanArguments(&cfg, "zap-api-scan.py", "wrk"), " ")
+	assert.True(t, strings.Contains(args, "--hook "+filepath.Join("wrk", ApiScanAuthHookFileName)))
+	assert.False(t, strings.Contains(args, "-n context.xml"))
+
+	env := strings.Join(ApiScanEnvironment(cfg.GetContexts()[0], "", "wrk"), ";")
+	assert.StringsAreEqual(t, "ZAP_OAUTH2_TOKEN_FILE="+filepath.Join("wrk", ApiScanOAuth2TokenFileName)+
+		`;ZAP_OAUTH2_HEADER=Authorization;ZAP_OAUTH2_HEADER_URL=^https?://api\.localhost([:/?#].*)?$`, env)
+
+	files := ApiScanFiles(&cfg, "wrk")
+	assert.StringsAreEqual(t, filepath.Join("wrk", ApiScanOAuth2TokenFileName), files[0].Path)
+}
+
+func TestApiScanArgumentsWithScriptAuthentication(t *testing.T) {
+
+	cfg := Config{}
+	cfg.Context.Target = "openapi.json"
+	cfg.Context.Format = "openapi"
+	cfg.Authentication.Type = "scriptAuthentication"
+	cfg.ScanOptions.RunActiveScan = true
+	cfg.credentials = Credentials{{Username: "admin", Password: "s3cret"}}
+
+	args := strings.Join(ApiScanArguments(&cfg, "zap-api-scan.py", "wrk"), " ")
+	assert.True(t, strings.Contains(args, "--hook wrk"))
+	assert.True(t, strings.HasSuffix(args, "-U admin -n context.xml"))
+
+	files := ApiScanFiles(&cfg, "wrk")
+	assert.IntsAreEqual(t, 3, len(files))
+}
+
+func TestApiScanContextFileWithCredentialsIsExported(t *testing.T) {
+
+	cfg := Config{}
+	cfg.Context.Name = "Imported"
+	cfg.Context.Target = "openapi.json"
+	cfg.Context.Format = "openapi"
+	cfg.Context.ContextFile = "context.xml"
+	cfg.contextFileContent = "<configuration><context><name>Imported</name></context></configuration>"
+	cfg.Authentication.Type = "formAuthentication"
+
+	assert.False(t, cfg.IsContextExportRequired())
+	assert.StringsAreEqual(t, "the context from context.contextFile", ApiScanFiles(&cfg, "wrk")[0].Description)
+
+	cfg.credentials = Credentials{{Username: "admin", Password: "s3cret"}}
+	assert.True(t, cfg.IsContextExportRequired())
+
+	plan := NewPlan(&cfg, "api", "zap-api-scan.py", "wrk")
+	assert.True(t, strings.Contains(plan.Phases[0], "import context.contextFile, apply the credentials and settings"))
+	assert.True(t, strings.HasSuffix(strings.Join(plan.ApiScan.Command, " "), "-U admin -n context.xml -S"))
+}
@@ -0,0 +1,224 @@
+package zap
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"html"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// minimumRedactedSecretLength is the length of the shortest secret that is redacted, so that a short value, such
+// as a one-character password, does not mask unrelated text.
+const minimumRedactedSecretLength = 4
+
+// Redactor replaces every loaded secret value, along with its common encodings, with [REDACTED].
+type Redactor struct {
+	mutex    sync.RWMutex
+	secrets  map[string]bool
+	replacer *strings.Replacer
+}
+
+func newRedactor() *Redactor {
+	return &Redactor{secrets: make(map[string]bool), replacer: strings.NewReplacer()}
+}
+
+// Add adds secret values, such as an access token fetched during the scan, to the values that are redacted.
+func (r *Redactor) Add(secrets ...string) {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, secret := range secrets {
+		if len(secret) < minimumRedactedSecretLength {
+			continue
+		}
+
+		encodedSecret, _ := json.Marshal(secret)
+		for _, s := range []string{
+			secret,
+			url.QueryEscape(secret),
+			url.PathEscape(secret),
+			html.EscapeString(secret),
+			strings.Trim(string(encodedSecret), `"`),
+			base64.StdEncoding.EncodeToString([]byte(secret)),
+		} {
+			r.secrets[s] = true
+		}
+	}
+
+	// replace longer values first so that a secret containing another is fully redacted
+	values := make([]string, 0, len(r.secrets))
+	for s := range r.secrets {
+		values = append(values, s)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if len(values[i]) != len(values[j]) {
+			return len(values[i]) > len(values[j])
+		}
+		return values[i] < values[j]
+	})
+
+	oldNew := make([]string, 0, 2*len(values))
+	for _, s := range values {
+		oldNew = append(oldNew, s, redactedValue)
+	}
+	r.replacer = strings.NewReplacer(oldNew...)
+}
+
+// Redact returns the text with every secret value replaced.
+func (r *Redactor) Redact(s string) string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.replacer.Replace(s)
+}
+
+// RedactFile replaces every secret value in a file, such as a report whose evidence includes a request header.
+func (r *Redactor) RedactFile(path string) error {
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	redactedContent := r.Redact(string(content))
+	if redactedContent == string(content) {
+		return nil
+	}
+	return ioutil.WriteFile(path, []byte(redactedContent), info.Mode())
+}
+
+// NewWriter returns a writer that redacts each line before writing it to w. Call Flush to write a final partial line.
+func (r *Redactor) NewWriter(w io.Writer) *RedactingWriter {
+	return &RedactingWriter{redactor: r, writer: w}
+}
+
+// RedactingWriter redacts complete lines so that a secret split across writes, such as the writes of a program's
+// output, is still redacted.
+type RedactingWriter struct {
+	mutex    sync.Mutex
+	redactor *Redactor
+	writer   io.Writer
+	pending  []byte
+}
+
+func (w *RedactingWriter) Write(p []byte) (int, error) {
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	w.pending = append(w.pending, p...)
+
+	end := bytes.LastIndexByte(w.pending, '\n')
+	if end == -1 {
+		return len(p), nil
+	}
+
+	lines := string(w.pending[:end+1])
+	w.pending = append([]byte(nil), w.pending[end+1:]...)
+
+	if _, err := io.WriteString(w.writer, w.redactor.Redact(lines)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush writes a partial line that does not yet end with a newline.
+func (w *RedactingWriter) Flush() error {
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if len(w.pending) == 0 {
+		return nil
+	}
+
+	partialLine := string(w.pending)
+	w.pending = nil
+
+	_, err := io.WriteString(w.writer, w.redactor.Redact(partialLine))
+	return err
+}
+
+// Redactor returns the redactor that knows the configuration's credential values and secret script parameters.
+func (c *Config) Redactor() *Redactor {
+	if c.redactor == nil {
+		c.redactor = newRedactor()
+		for _, ctx := range c.GetContexts() {
+			ctx.redactor = c.redactor
+			ctx.addSecrets(c.redactor)
+		}
+	}
+	return c.redactor
+}
+
+func (c *ContextConfig) addSecrets(r *Redactor) {
+
+	for _, cred := range c.GetCredentials() {
+		r.Add(cred.Password, cred.RefreshToken, cred.TotpSecret)
+		if cred.Username != "" && cred.Password != "" {
+			// the value of a basic authentication header
+			r.Add(cred.Username + ":" + cred.Password)
+		}
+	}
+
+	for _, parameter := range c.ScriptAuthentication.AuthenticationScriptParameters {
+		if sensitiveNames.MatchString(parameter.Name) {
+			r.Add(parameter.Value)
+		}
+	}
+}
+
+// addTokenSecrets redacts the values of an access token fetched for the context.
+func (c *ContextConfig) addTokenSecrets(token OAuth2Token) {
+	if c.redactor != nil {
+		c.redactor.Add(token.AccessToken, token.RefreshToken)
+	}
+}
+
+// SecureDelete overwrites a file with zeros before removing it. It does nothing when the file does not exist.
+// Overwriting is best-effort on file systems, such as copy-on-write ones, that write new data to new blocks.
+func SecureDelete(path string) error {
+
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(make([]byte, info.Size())); err != nil {
+		if err := f.Close(); err != nil {
+			log.Println(err)
+		}
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		if err := f.Close(); err != nil {
+			log.Println(err)
+		}
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
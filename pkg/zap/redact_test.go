@@ -0,0 +1,79 @@
+package zap
+
+import (
+	"bytes"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/codedx/codedx-add-ins/pkg/assert"
+)
+
+func TestRedactorRedactsEncodedSecrets(t *testing.T) {
+
+	cfg := Config{}
+	cfg.ScriptAuthentication.AuthenticationScriptParameters = []scriptParameter{{Name: "apiKey", Value: "k3y-value"}, {Name: "loginUrl", Value: "http://localhost/login"}}
+	cfg.credentials = Credentials{{Username: "admin", Password: "p@ss word&"}, {Username: "short", Password: "abc"}}
+
+	r := cfg.Redactor()
+
+	assert.StringsAreEqual(t, "password=[REDACTED]", r.Redact("password=p@ss word&"))
+	assert.StringsAreEqual(t, "password=[REDACTED]", r.Redact("password=p%40ss+word%26"))
+	assert.StringsAreEqual(t, "<evidence>[REDACTED]</evidence>", r.Redact("<evidence>p@ss word&amp;</evidence>"))
+	assert.StringsAreEqual(t, "Authorization: Basic [REDACTED]", r.Redact("Authorization: Basic "+base64.StdEncoding.EncodeToString([]byte("admin:p@ss word&"))))
+	assert.StringsAreEqual(t, "key=[REDACTED] url=http://localhost/login", r.Redact("key=k3y-value url=http://localhost/login"))
+
+	// short values are not redacted
+	assert.StringsAreEqual(t, "abc", r.Redact("abc"))
+
+	cfg.GetContexts()[0].addTokenSecrets(OAuth2Token{AccessToken: "eyJ0b2tlbg"})
+	assert.StringsAreEqual(t, "Bearer [REDACTED]", r.Redact("Bearer eyJ0b2tlbg"))
+}
+
+func TestRedactingWriterRedactsSplitWrites(t *testing.T) {
+
+	r := newRedactor()
+	r.Add("s3cret-value")
+
+	var b bytes.Buffer
+	w := r.NewWriter(&b)
+
+	_, err := w.Write([]byte("first s3cr"))
+	assert.NilError(t, err)
+	assert.StringsAreEqual(t, "", b.String())
+
+	_, err = w.Write([]byte("et-value line\nsecond s3cret-value"))
+	assert.NilError(t, err)
+	assert.StringsAreEqual(t, "first [REDACTED] line\n", b.String())
+
+	assert.NilError(t, w.Flush())
+	assert.StringsAreEqual(t, "first [REDACTED] line\nsecond [REDACTED]", b.String())
+}
+
+func TestRedactFile(t *testing.T) {
+
+	r := newRedactor()
+	r.Add("s3cret-value")
+
+	report := filepath.Join(t.TempDir(), "report.xml")
+	assert.NilError(t, os.WriteFile(report, []byte("<evidence>token=s3cret-value</evidence>"), 0600))
+
+	assert.NilError(t, r.RedactFile(report))
+	content, err := os.ReadFile(report)
+	assert.NilError(t, err)
+	assert.StringsAreEqual(t, "<evidence>token=[REDACTED]</evidence>", string(content))
+}
+
+func TestSecureDelete(t *testing.T) {
+
+	authScript := filepath.Join(t.TempDir(), "authScript")
+	assert.NilError(t, os.WriteFile(authScript, []byte("password"), 0600))
+
+	assert.NilError(t, SecureDelete(authScript))
+	_, err := os.Stat(authScript)
+	assert.True(t, os.IsNotExist(err))
+
+	// a missing file is not an error
+	assert.NilError(t, SecureDelete(authScript))
+}
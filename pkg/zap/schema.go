@@ -0,0 +1,214 @@
+package zap
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"go.yaml.in/yaml/v3"
+)
+
+// CurrentSchemaVersion is the version of the request file layout that Config describes. Request files without a
+// schemaVersion use version 1, the layout that predates schema versions.
+const CurrentSchemaVersion = 2
+
+type schemaMigration struct {
+	version int                                            // the schema version that the migration produces
+	migrate func(settings map[string]interface{}) []string // updates settings in place and returns deprecation warnings
+}
+
+// schemaMigrations lists, in version order, the changes that upgrade a request file to the next schema version.
+var schemaMigrations = []schemaMigration{
+	{version: 2, migrate: migrateAntiCrossSiteRequestForgeryFieldName},
+}
+
+// migrateSettings upgrades request file settings, whose keys may use any case, to the current schema version. It
+// returns a deprecation warning for each change.
+func migrateSettings(settings map[string]interface{}) ([]string, error) {
+
+	version := 1
+	versionKey, ok := findSettingKey(settings, "schemaVersion")
+	if ok {
+		v, err := settingInt(settings[versionKey])
+		if err != nil {
+			return nil, fmt.Errorf("unable to read schemaVersion: %s", err.Error())
+		}
+		version = v
+	} else {
+		versionKey = "schemaVersion"
+	}
+
+	if version < 1 || version > CurrentSchemaVersion {
+		return nil, fmt.Errorf("unsupported schemaVersion %d; this version of the ZAP runner supports schema versions 1 through %d", version, CurrentSchemaVersion)
+	}
+
+	warnings := make([]string, 0)
+	for _, migration := range schemaMigrations {
+		if migration.version > version {
+			warnings = append(warnings, migration.migrate(settings)...)
+		}
+	}
+	settings[versionKey] = CurrentSchemaVersion
+	return warnings, nil
+}
+
+// migrateAntiCrossSiteRequestForgeryFieldName moves the formAntiCrossSiteRequestForgeryFieldName value, replaced by
+// formAntiCrossSiteRequestForgeryFieldNames in schema version 2, to the front of the list.
+func migrateAntiCrossSiteRequestForgeryFieldName(settings map[string]interface{}) []string {
+
+	warnings := make([]string, 0)
+	forEachContextSettings(settings, func(prefix string, contextSettings map[string]interface{}) {
+
+		formAuth, ok := findSettingMap(contextSettings, "formAuthentication")
+		if !ok {
+			return
+		}
+
+		nameKey, ok := findSettingKey(formAuth, "formAntiCrossSiteRequestForgeryFieldName")
+		if !ok {
+			return
+		}
+		name, _ := formAuth[nameKey].(string)
+		delete(formAuth, nameKey)
+
+		namesKey, ok := findSettingKey(formAuth, "formAntiCrossSiteRequestForgeryFieldNames")
+		if !ok {
+			namesKey = "formAntiCrossSiteRequestForgeryFieldNames"
+		}
+		names, _ := formAuth[namesKey].([]interface{})
+		if name != "" {
+			names = append([]interface{}{name}, names...)
+		}
+		formAuth[namesKey] = names
+
+		warnings = append(warnings, fmt.Sprintf("%sformAuthentication.formAntiCrossSiteRequestForgeryFieldName is deprecated; use formAntiCrossSiteRequestForgeryFieldNames", prefix))
+	})
+	return warnings
+}
+
+// forEachContextSettings calls fn with the top-level settings and the settings of each contexts array entry.
+func forEachContextSettings(settings map[string]interface{}, fn func(prefix string, contextSettings map[string]interface{})) {
+
+	fn("", settings)
+
+	contextsKey, ok := findSettingKey(settings, "contexts")
+	if !ok {
+		return
+	}
+	contexts, _ := settings[contextsKey].([]interface{})
+	for i, ctx := range contexts {
+		if contextSettings, ok := settingMap(ctx); ok {
+			fn(fmt.Sprintf("contexts[%d].", i), contextSettings)
+		}
+	}
+}
+
+func findSettingKey(settings map[string]interface{}, name string) (string, bool) {
+	for key := range settings {
+		if strings.EqualFold(key, name) {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+func findSettingMap(settings map[string]interface{}, name string) (map[string]interface{}, bool) {
+	key, ok := findSettingKey(settings, name)
+	if !ok {
+		return nil, false
+	}
+	return settingMap(settings[key])
+}
+
+func settingMap(value interface{}) (map[string]interface{}, bool) {
+	m, ok := value.(map[string]interface{})
+	return m, ok
+}
+
+func settingInt(value interface{}) (int, error) {
+	switch v := value.(type) {
+	case int:
+		return v, nil
+	case int64:
+		return int(v), nil
+	case float64:
+		return int(v), nil
+	}
+	return 0, fmt.Errorf("expected a number instead of %v", value)
+}
+
+// MigrateRequestFile rewrites TOML, JSON, or YAML request file content in the current schema. It returns the
+// rewritten content and a deprecation warning for each change. Comments are not preserved.
+func MigrateRequestFile(content []byte, format string) ([]byte, []string, error) {
+
+	settings := make(map[string]interface{})
+
+	var err error
+	switch format {
+	case "toml":
+		err = toml.Unmarshal(content, &settings)
+	case "json":
+		err = json.Unmarshal(content, &settings)
+	case "yaml":
+		err = yaml.Unmarshal(content, &settings)
+	default:
+		err = fmt.Errorf("unsupported format %s", format)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	normalizeSettings(settings)
+	warnings, err := migrateSettings(settings)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var b bytes.Buffer
+	switch format {
+	case "toml":
+		err = toml.NewEncoder(&b).Encode(settings)
+	case "json":
+		encoder := json.NewEncoder(&b)
+		encoder.SetIndent("", "  ")
+		encoder.SetEscapeHTML(false)
+		err = encoder.Encode(settings)
+	case "yaml":
+		err = yaml.NewEncoder(&b).Encode(settings)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return b.Bytes(), warnings, nil
+}
+
+// normalizeSettings converts the nested maps and arrays produced by each decoder to map[string]interface{} and
+// []interface{}.
+func normalizeSettings(settings map[string]interface{}) {
+	for key, value := range settings {
+		settings[key] = normalizeSetting(value)
+	}
+}
+
+func normalizeSetting(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		normalizeSettings(v)
+		return v
+	case []map[string]interface{}:
+		values := make([]interface{}, len(v))
+		for i, m := range v {
+			normalizeSettings(m)
+			values[i] = m
+		}
+		return values
+	case []interface{}:
+		for i := range v {
+			v[i] = normalizeSetting(v[i])
+		}
+		return v
+	}
+	return value
+}
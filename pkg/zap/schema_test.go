@@ -0,0 +1,72 @@
+package zap
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/codedx/codedx-add-ins/pkg/assert"
+)
+
+func TestParseConfigMigratesSchemaVersion1(t *testing.T) {
+
+	cfg, err := ParseConfigReader(strings.NewReader(`
+[context]
+target = "http://localhost/"
+
+[formAuthentication]
+formAntiCrossSiteRequestForgeryFieldName = "csrf"
+formAntiCrossSiteRequestForgeryFieldNames = ["nonce"]
+
+[[contexts]]
+[contexts.context]
+target = "http://localhost/admin"
+[contexts.formAuthentication]
+formAntiCrossSiteRequestForgeryFieldName = "token"
+
+[request]
+workDirectory = "`+filepath.ToSlash(t.TempDir())+`"
+`), "normal")
+	assert.NilError(t, err)
+	assert.IntsAreEqual(t, CurrentSchemaVersion, cfg.SchemaVersion)
+	assert.StringsAreEqual(t, "csrf;nonce", strings.Join(cfg.FormAuthentication.antiCrossSiteRequestForgeryFieldNames(), ";"))
+	assert.StringsAreEqual(t, "token", strings.Join(cfg.Contexts[0].FormAuthentication.antiCrossSiteRequestForgeryFieldNames(), ";"))
+}
+
+func TestParseConfigRejectsNewerSchemaVersion(t *testing.T) {
+
+	_, err := ParseConfigReader(strings.NewReader(`
+schemaVersion = 99
+[context]
+target = "http://localhost/"
+`), "normal")
+	assert.NotNil(t, err)
+}
+
+func TestMigrateRequestFile(t *testing.T) {
+
+	content, warnings, err := MigrateRequestFile([]byte(`{
+  "formAuthentication": {"formAntiCrossSiteRequestForgeryFieldName": "csrf"},
+  "request": {"shellCmd": "zap"}
+}`), "json")
+	assert.NilError(t, err)
+	assert.IntsAreEqual(t, 1, len(warnings))
+	assert.True(t, strings.Contains(string(content), `"formAntiCrossSiteRequestForgeryFieldNames": [
+      "csrf"
+    ]`))
+	assert.True(t, strings.Contains(string(content), `"schemaVersion": 2`))
+	assert.True(t, strings.Contains(string(content), `"shellCmd": "zap"`))
+
+	content, warnings, err = MigrateRequestFile([]byte(`
+schemaVersion = 2
+[context]
+target = "http://localhost/"
+`), "toml")
+	assert.NilError(t, err)
+	assert.IntsAreEqual(t, 0, len(warnings))
+	assert.True(t, strings.Contains(string(content), "target = 'http://localhost/'"))
+
+	content, _, err = MigrateRequestFile([]byte("formAuthentication:\n  formAntiCrossSiteRequestForgeryFieldName: csrf\n"), "yaml")
+	assert.NilError(t, err)
+	assert.True(t, strings.Contains(string(content), "- csrf"))
+}
@@ -0,0 +1,201 @@
+package zap
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/zaproxy/zap-api-go/zap"
+)
+
+type scope struct {
+	AllowedHosts []string // hostnames, or *.example.com for any subdomain of example.com
+	Strict       bool
+}
+
+// IsEnabled reports whether the scan is limited to an allowlist of hosts.
+func (s scope) IsEnabled() bool {
+	return len(s.AllowedHosts) > 0
+}
+
+// IsAllowedHost reports whether a hostname, with or without a port, is on the allowlist.
+func (s scope) IsAllowedHost(host string) bool {
+
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+	for _, allowedHost := range s.AllowedHosts {
+		allowedHost = strings.ToLower(strings.TrimSpace(allowedHost))
+		if strings.HasPrefix(allowedHost, "*.") {
+			if strings.HasSuffix(host, allowedHost[1:]) {
+				return true
+			}
+		} else if host == allowedHost {
+			return true
+		}
+	}
+	return false
+}
+
+// isAllowedURL reports whether the host of a URL with an http or https scheme is on the allowlist. Other values, such
+// as a path to an API definition file, are allowed.
+func (s scope) isAllowedURL(value string) bool {
+	u, err := url.Parse(value)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return true
+	}
+	return s.IsAllowedHost(u.Host)
+}
+
+// excludeRegularExpression returns a regular expression matching every URL whose host is not on the allowlist.
+func (s scope) excludeRegularExpression() string {
+
+	hosts := make([]string, 0)
+	for _, allowedHost := range s.AllowedHosts {
+		allowedHost = strings.TrimSpace(allowedHost)
+		if strings.HasPrefix(allowedHost, "*.") {
+			hosts = append(hosts, `[^/?#:@]+`+regexp.QuoteMeta(allowedHost[1:]))
+		} else {
+			hosts = append(hosts, regexp.QuoteMeta(allowedHost))
+		}
+	}
+	return `(?i)^https?://(?!(` + strings.Join(hosts, "|") + `)(:\d+)?([/?#].*)?$).*$`
+}
+
+// outOfScopeGlobalExcludeURLIndex is the global exclude URL list index of the allowlist exclusion. It is past ZAP's
+// default global exclude URLs, so ZAP adds the exclusion as a new entry instead of replacing one of its defaults.
+const outOfScopeGlobalExcludeURLIndex = 100
+
+// ZapConfigOptions returns the ZAP command-line options that add a global exclude URL for hosts outside of the
+// allowlist, which stops ZAP's proxy, spider, and scanners from sending requests to them. The options contain no
+// spaces, so zap-api-scan can accept them as a single -z argument.
+func (s scope) ZapConfigOptions() []string {
+
+	if !s.IsEnabled() {
+		return nil
+	}
+
+	key := fmt.Sprintf("globalexcludeurl.url_list.url(%d)", outOfScopeGlobalExcludeURLIndex)
+	return []string{
+		"-config", key + ".regex=" + s.excludeRegularExpression(),
+		"-config", key + ".description=out-of-scope-hosts",
+		"-config", key + ".enabled=true",
+	}
+}
+
+// FindOutOfScopeHosts returns the hosts outside of the allowlist that ZAP accessed or that its spider attempted to
+// access. ZAP does not record the requests that the global exclude URLs stop, so the hosts come from the URLs that
+// each spider scan reported as out of scope in addition to the hosts that ZAP recorded.
+func FindOutOfScopeHosts(zap *zap.Interface, s scope) ([]string, error) {
+
+	if !s.IsEnabled() {
+		return nil, nil
+	}
+
+	result, err := (*zap).Core().Hosts()
+	if err != nil {
+		return nil, err
+	}
+
+	hosts, err := getZapStringListResult("hosts", result)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err = (*zap).Spider().Scans()
+	if err != nil {
+		return nil, err
+	}
+
+	urls := make([]string, 0)
+	for _, scanID := range readSpiderScanIDs(result) {
+		fullResults, err := (*zap).Spider().FullResults(scanID)
+		if err != nil {
+			return nil, err
+		}
+		urls = append(urls, readSpiderOutOfScopeURLs(fullResults)...)
+	}
+
+	return s.outOfScopeHosts(hosts, urls), nil
+}
+
+// FindApiDefinitionOutOfScopeHosts returns the hosts outside of the allowlist that the API definition of an API
+// scan's context directs ZAP to access.
+func FindApiDefinitionOutOfScopeHosts(cfg *ContextConfig, s scope, zapWorkDir string) ([]string, error) {
+
+	if !s.IsEnabled() {
+		return nil, nil
+	}
+
+	hosts, err := ApiDefinitionHosts(cfg, zapWorkDir)
+	if err != nil {
+		return nil, err
+	}
+	return s.outOfScopeHosts(hosts, nil), nil
+}
+
+// outOfScopeHosts returns the sorted, distinct hosts outside of the allowlist from a list of hosts and the hosts of
+// a list of URLs.
+func (s scope) outOfScopeHosts(hosts []string, urls []string) []string {
+
+	for _, value := range urls {
+		if u, err := url.Parse(value); err == nil && u.Host != "" {
+			hosts = append(hosts, u.Hostname())
+		}
+	}
+
+	found := make(map[string]bool)
+	outOfScopeHosts := make([]string, 0)
+	for _, host := range hosts {
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		host = strings.ToLower(host)
+		if !found[host] && !s.IsAllowedHost(host) {
+			found[host] = true
+			outOfScopeHosts = append(outOfScopeHosts, host)
+		}
+	}
+	sort.Strings(outOfScopeHosts)
+	return outOfScopeHosts
+}
+
+// readSpiderScanIDs returns the IDs in the result of the spider/view/scans API.
+func readSpiderScanIDs(result map[string]interface{}) []string {
+
+	ids := make([]string, 0)
+	scans, _ := result["scans"].([]interface{})
+	for _, v := range scans {
+		if fields, ok := v.(map[string]interface{}); ok {
+			if id, ok := fields["id"].(string); ok {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids
+}
+
+// readSpiderOutOfScopeURLs returns the urlsOutOfScope in the result of the spider/view/fullResults API.
+func readSpiderOutOfScopeURLs(result map[string]interface{}) []string {
+
+	urls := make([]string, 0)
+	fullResults, _ := result["fullResults"].([]interface{})
+	for _, v := range fullResults {
+		fields, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		list, _ := fields["urlsOutOfScope"].([]interface{})
+		for _, u := range list {
+			if value, ok := u.(string); ok {
+				urls = append(urls, value)
+			}
+		}
+	}
+	return urls
+}
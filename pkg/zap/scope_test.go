@@ -0,0 +1,140 @@
+package zap
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/codedx/codedx-add-ins/pkg/assert"
+)
+
+func TestScopeIsAllowedHost(t *testing.T) {
+
+	s := scope{AllowedHosts: []string{"app.example.com", "*.internal.example.com"}}
+
+	assert.True(t, s.IsAllowedHost("app.example.com"))
+	assert.True(t, s.IsAllowedHost("APP.example.com:8443"))
+	assert.True(t, s.IsAllowedHost("api.internal.example.com"))
+	assert.False(t, s.IsAllowedHost("internal.example.com"))
+	assert.False(t, s.IsAllowedHost("cdn.example.net"))
+	assert.False(t, s.IsAllowedHost("app.example.com.evil.net"))
+
+	assert.True(t, s.isAllowedURL("https://app.example.com/login"))
+	assert.True(t, s.isAllowedURL("openapi.json"))
+	assert.False(t, s.isAllowedURL("https://www.google-analytics.com/"))
+}
+
+func TestScopeZapConfigOptions(t *testing.T) {
+
+	assert.IntsAreEqual(t, 0, len(scope{}.ZapConfigOptions()))
+
+	s := scope{AllowedHosts: []string{"app.example.com", "*.internal.example.com"}}
+	assert.StringsAreEqual(t, `(?i)^https?://(?!(app\.example\.com|[^/?#:@]+\.internal\.example\.com)(:\d+)?([/?#].*)?$).*$`, s.excludeRegularExpression())
+
+	options := s.ZapConfigOptions()
+	assert.IntsAreEqual(t, 6, len(options))
+	assert.StringsAreEqual(t, "globalexcludeurl.url_list.url(100).regex="+s.excludeRegularExpression(), options[1])
+	assert.StringsAreEqual(t, "globalexcludeurl.url_list.url(100).description=out-of-scope-hosts", options[3])
+}
+
+func TestIsValidScope(t *testing.T) {
+
+	cfg := Config{}
+	cfg.Context.Name = "Context"
+	cfg.Context.Target = "https://app.example.com/"
+	cfg.Scope.AllowedHosts = []string{"app.example.com"}
+	cfg.Scope.Strict = true
+	assert.True(t, cfg.IsValid("normal"))
+
+	cfg.Context.Format = "openapi"
+	assert.True(t, cfg.IsValid("api"))
+
+	cfg.Context.Format = ""
+	cfg.Context.Target = "https://other.example.com/"
+	assert.False(t, cfg.IsValid("normal"))
+}
+
+func TestFindOutOfScopeHostsInSpiderResults(t *testing.T) {
+
+	scans := map[string]interface{}{
+		"scans": []interface{}{
+			map[string]interface{}{"id": "0", "progress": "100", "state": "FINISHED"},
+			map[string]interface{}{"id": "1", "progress": "100", "state": "FINISHED"},
+		},
+	}
+	assert.StringsAreEqual(t, "0;1", strings.Join(readSpiderScanIDs(scans), ";"))
+
+	fullResults := map[string]interface{}{
+		"fullResults": []interface{}{
+			map[string]interface{}{"urlsInScope": []interface{}{
+				map[string]interface{}{"url": "https://app.example.com/", "statusCode": "200"},
+			}},
+			map[string]interface{}{"urlsOutOfScope": []interface{}{
+				"https://www.google-analytics.com/analytics.js",
+				"https://api.internal.example.com/v1",
+				"https://cdn.example.net:8443/app.css",
+				"https://www.google-analytics.com/collect",
+			}},
+			map[string]interface{}{"urlsIoError": []interface{}{}},
+		},
+	}
+	urls := readSpiderOutOfScopeURLs(fullResults)
+	assert.IntsAreEqual(t, 4, len(urls))
+
+	s := scope{AllowedHosts: []string{"app.example.com", "*.internal.example.com"}}
+	hosts := s.outOfScopeHosts([]string{"app.example.com:443", "Tracker.example.org"}, urls)
+	assert.StringsAreEqual(t, "cdn.example.net;tracker.example.org;www.google-analytics.com", strings.Join(hosts, ";"))
+}
+
+func TestFindApiDefinitionOutOfScopeHosts(t *testing.T) {
+
+	zapWorkDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(zapWorkDir, "openapi.yaml"), []byte(`
+openapi: 3.0.0
+servers:
+  - url: https://app.example.com/v1
+  - url: https://{environment}.example.com/v1
+    variables:
+      environment:
+        default: prod
+  - url: /v2
+`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	s := scope{AllowedHosts: []string{"app.example.com"}}
+
+	cfg := ContextConfig{}
+	cfg.Context.Target = "openapi.yaml"
+	cfg.Context.Format = "openapi"
+
+	hosts, err := FindApiDefinitionOutOfScopeHosts(&cfg, s, zapWorkDir)
+	assert.NilError(t, err)
+	assert.StringsAreEqual(t, "prod.example.com", strings.Join(hosts, ";"))
+
+	cfg.Context.OpenApiHostnameOverride = "app.example.com:8443"
+	hosts, err = FindApiDefinitionOutOfScopeHosts(&cfg, s, zapWorkDir)
+	assert.NilError(t, err)
+	assert.IntsAreEqual(t, 0, len(hosts))
+
+	cfg.Context.OpenApiHostnameOverride = ""
+	cfg.Context.Target = "missing.json"
+	_, err = FindApiDefinitionOutOfScopeHosts(&cfg, s, zapWorkDir)
+	assert.NotNil(t, err)
+}
+
+func TestReadApiDefinitionHosts(t *testing.T) {
+
+	hosts, err := readApiDefinitionHosts([]byte(`{"swagger": "2.0", "host": "petstore.example.com", "basePath": "/v2"}`), "openapi")
+	assert.NilError(t, err)
+	assert.StringsAreEqual(t, "petstore.example.com", strings.Join(hosts, ";"))
+
+	hosts, err = readApiDefinitionHosts([]byte(`<definitions><service name="Calculator"><port name="CalculatorSoap">
+<soap:address location="http://www.dneonline.com/calculator.asmx" /></port></service></definitions>`), "soap")
+	assert.NilError(t, err)
+	assert.StringsAreEqual(t, "www.dneonline.com", strings.Join(hosts, ";"))
+
+	_, err = readApiDefinitionHosts([]byte(`servers: [`), "openapi")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,172 @@
+package zap
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/zaproxy/zap-api-go/zap"
+)
+
+const standardParameterParserClass = "org.zaproxy.zap.model.StandardParameterParser"
+
+var (
+	urlParserElement      = regexp.MustCompile(`(?s)<urlparser>.*?</urlparser>`)
+	dataDrivenNodeElement = regexp.MustCompile(`<ddns>`)
+)
+
+type urlParser struct {
+	Class  string `xml:"class"`
+	Config string `xml:"config"`
+}
+
+// HasContextStructure reports whether the context defines structural parameters or data-driven nodes.
+func (c *ContextConfig) HasContextStructure() bool {
+	return len(c.Context.StructuralParameters) > 0 || len(c.Context.DataDrivenNodes) > 0
+}
+
+// restructureContext adds the context's structural parameters and data-driven nodes, which the ZAP API cannot set
+// directly, by exporting the context, updating the context file, and importing it in place of the original
+// context. It returns the ID of the imported context.
+func restructureContext(zap *zap.Interface, cfg *ContextConfig, contextID string) (string, error) {
+
+	if !cfg.HasContextStructure() {
+		return contextID, nil
+	}
+
+	dir, err := ioutil.TempDir("", "context")
+	if err != nil {
+		return "", err
+	}
+	contextFile := filepath.Join(dir, "context.xml")
+	defer func() {
+		// the exported context includes the credentials of its users
+		if err := SecureDelete(contextFile); err != nil {
+			log.Println(err)
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			log.Println(err)
+		}
+	}()
+	if _, err := (*zap).Context().ExportContext(cfg.Context.Name, contextFile); err != nil {
+		return "", err
+	}
+
+	content, err := ioutil.ReadFile(contextFile)
+	if err != nil {
+		return "", err
+	}
+
+	updatedContent, err := applyContextStructure(string(content), cfg.Context.StructuralParameters, cfg.Context.DataDrivenNodes)
+	if err != nil {
+		return "", err
+	}
+
+	if err := ioutil.WriteFile(contextFile, []byte(updatedContent), 0600); err != nil {
+		return "", err
+	}
+
+	if _, err := (*zap).Context().RemoveContext(cfg.Context.Name); err != nil {
+		return "", err
+	}
+
+	result, err := (*zap).Context().ImportContext(contextFile)
+	if err != nil {
+		return "", err
+	}
+	return getZapStringResult("contextId", result)
+}
+
+// applyContextStructure adds structural parameters to the URL parameter parser of ZAP context file content and adds
+// a data-driven node for each regular expression, which must have two or three groups, with the second group
+// matching the data-driven part of the URL (e.g., (https://localhost/product/)(.+?)(/.*)).
+func applyContextStructure(content string, structuralParameters []string, dataDrivenNodes []string) (string, error) {
+
+	end := strings.LastIndex(content, "</context>")
+	if end == -1 {
+		return "", fmt.Errorf("unable to find the context element in the context file")
+	}
+
+	if len(structuralParameters) > 0 {
+
+		parser := urlParser{Class: standardParameterParserClass, Config: `{"kvps":"&","kvs":"=","struct":[]}`}
+
+		existing := urlParserElement.FindString(content)
+		if existing != "" {
+			if err := xml.Unmarshal([]byte(existing), &parser); err != nil {
+				return "", fmt.Errorf("unable to read the context URL parser: %s", err.Error())
+			}
+			if parser.Class != standardParameterParserClass {
+				return "", fmt.Errorf("unable to add structural parameters to URL parser %s", parser.Class)
+			}
+		}
+
+		config := make(map[string]interface{})
+		if err := json.Unmarshal([]byte(parser.Config), &config); err != nil {
+			return "", fmt.Errorf("unable to read the context URL parser configuration: %s", err.Error())
+		}
+
+		names := make([]string, 0)
+		if values, ok := config["struct"].([]interface{}); ok {
+			for _, v := range values {
+				if name, ok := v.(string); ok {
+					names = append(names, name)
+				}
+			}
+		}
+		config["struct"] = appendUnique(names, structuralParameters...)
+
+		var parserConfig bytes.Buffer
+		encoder := json.NewEncoder(&parserConfig)
+		encoder.SetEscapeHTML(false)
+		if err := encoder.Encode(config); err != nil {
+			return "", err
+		}
+
+		element := "<urlparser><class>" + escapeXML(parser.Class) + "</class><config>" + escapeXML(strings.TrimSpace(parserConfig.String())) + "</config></urlparser>"
+		if existing != "" {
+			content = strings.Replace(content, existing, element, 1)
+		} else {
+			content = content[:end] + element + content[end:]
+		}
+		end = strings.LastIndex(content, "</context>")
+	}
+
+	var elements strings.Builder
+	nodeCount := len(dataDrivenNodeElement.FindAllStringIndex(content, -1))
+	for _, pattern := range dataDrivenNodes {
+		if err := validateDataDrivenNode(pattern); err != nil {
+			return "", fmt.Errorf("data-driven node regular expression %s %s", pattern, err.Error())
+		}
+		nodeCount++
+		elements.WriteString(fmt.Sprintf("<ddns>DataDrivenNode:DDN%d:%s</ddns>", nodeCount, escapeXML(pattern)))
+	}
+	return content[:end] + elements.String() + content[end:], nil
+}
+
+// validateDataDrivenNode returns an error when a data-driven node regular expression cannot be parsed or does not
+// have the second group that matches the data-driven part of the URL path.
+func validateDataDrivenNode(pattern string) error {
+	exp, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("is invalid: %s", err.Error())
+	}
+	if exp.NumSubexp() < 2 {
+		return errors.New("must have two or three groups")
+	}
+	return nil
+}
+
+func escapeXML(s string) string {
+	var b bytes.Buffer
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,65 @@
+package zap
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/codedx/codedx-add-ins/pkg/assert"
+)
+
+const exportedContext = `<?xml version="1.0" encoding="UTF-8" standalone="no"?>
+<configuration>
+<context>
+<name>Context</name>
+<incregexes>http://localhost/.*</incregexes>
+<urlparser>
+<class>org.zaproxy.zap.model.StandardParameterParser</class>
+<config>{"kvps":"&amp;","kvs":"=","struct":["action"]}</config>
+</urlparser>
+</context>
+</configuration>`
+
+func TestApplyContextStructure(t *testing.T) {
+
+	content, err := applyContextStructure(exportedContext, []string{"page", "action"}, []string{"(http://localhost/product/)(.+?)(/.*)"})
+	assert.NilError(t, err)
+
+	assert.True(t, strings.Contains(content, `<config>{&#34;kvps&#34;:&#34;&amp;&#34;,&#34;kvs&#34;:&#34;=&#34;,&#34;struct&#34;:[&#34;action&#34;,&#34;page&#34;]}</config>`))
+	assert.IntsAreEqual(t, 1, strings.Count(content, "<urlparser>"))
+	assert.True(t, strings.Contains(content, "<ddns>DataDrivenNode:DDN1:(http://localhost/product/)(.+?)(/.*)</ddns></context>"))
+
+	_, err = applyContextStructure(exportedContext, nil, []string{"http://localhost/product/.*"})
+	assert.NotNil(t, err)
+
+	content, err = applyContextStructure("<configuration><context><name>Context</name></context></configuration>", []string{"page"}, nil)
+	assert.NilError(t, err)
+	assert.True(t, strings.Contains(content, "<urlparser><class>"+standardParameterParserClass+"</class>"))
+}
+
+func TestValidateDataDrivenNodes(t *testing.T) {
+
+	cfg := Config{}
+	cfg.Context.Name = "Context"
+	cfg.Context.Target = "http://localhost"
+	cfg.Context.DataDrivenNodes = []string{`(http://localhost/product/)(.+?)(/.*)`, `(http://localhost/)(?<=/)(\d+)`, `(http://localhost/item/)`}
+
+	keys := validationErrorKeys(t, cfg.Validate("normal"))
+	assert.IntsAreEqual(t, 2, len(keys))
+	assert.StringsAreEqual(t, "context.dataDrivenNodes[1]", keys[0])
+	assert.StringsAreEqual(t, "context.dataDrivenNodes[2]", keys[1])
+}
+
+func TestApiScanContextIncludesStructure(t *testing.T) {
+
+	cfg, err := ParseConfigReader(strings.NewReader(`
+[context]
+target = "openapi.json"
+format = "openapi"
+structuralParameters = ["page"]
+`), "api")
+	assert.NilError(t, err)
+
+	assert.True(t, cfg.IsContextFileRequired())
+	assert.StringsAreEqual(t, ".*", strings.Join(cfg.Context.IncludeRegularExpressions, ";"))
+	assert.True(t, strings.Contains(strings.Join(ApiScanArguments(cfg, "zap-api-scan.py", "wrk"), " "), "-n context.xml"))
+}
@@ -0,0 +1,217 @@
+package zap
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// expandTargets replaces each context that has a targets list with one context per target, so that one ZAP
+// instance spiders and scans the targets in turn. A target's context shares every other setting, including the
+// credentials, of the context listing it, and is named after the target, or after the context and the target's
+// position when the target has no name.
+func expandTargets(config *Config, scanMode string) error {
+
+	var errs ValidationErrors
+
+	hasTargets := false
+	contexts := make([]ContextConfig, 0)
+	for i, ctx := range config.GetContexts() {
+
+		prefix := ""
+		if len(config.Contexts) > 0 {
+			prefix = fmt.Sprintf("contexts[%d].", i)
+		}
+
+		if len(ctx.Context.Targets) == 0 {
+			contextConfig := *ctx
+			contextConfig.expansion = &contextExpansion{keyPrefix: prefix}
+			contexts = append(contexts, contextConfig)
+			continue
+		}
+		hasTargets = true
+
+		if IsApiScan(scanMode) {
+			errs.add(prefix+"context.targets", "is supported by normal scans only")
+			continue
+		}
+		if ctx.Context.Target != "" {
+			errs.add(prefix+"context.targets", "cannot be combined with context.target")
+		}
+		if ctx.Context.ContextFile != "" {
+			// the context file names every context that it creates
+			errs.add(prefix+"context.targets", "cannot be combined with context.contextFile")
+		}
+
+		contextName := ctx.Context.Name
+		if contextName == "" {
+			contextName = "Context"
+			if i > 0 {
+				contextName = fmt.Sprintf("Context%d", i+1)
+			}
+		}
+
+		for j, t := range ctx.Context.Targets {
+
+			if t.URL == "" {
+				errs.add(fmt.Sprintf("%scontext.targets[%d].url", prefix, j), "is required")
+			}
+
+			targetConfig := *ctx
+			targetConfig.Context.Target = t.URL
+			targetConfig.Context.Targets = nil
+			targetConfig.Context.Name = t.Name
+			if t.Name == "" {
+				targetConfig.Context.Name = fmt.Sprintf("%s-%d", contextName, j+1)
+			}
+			if len(t.IncludeRegularExpressions) > 0 {
+				targetConfig.Context.IncludeRegularExpressions = t.IncludeRegularExpressions
+			}
+			if len(t.ExcludeRegularExpressions) > 0 {
+				targetConfig.Context.ExcludeRegularExpressions = t.ExcludeRegularExpressions
+			}
+			targetConfig.isTarget = true
+			targetConfig.expansion = &contextExpansion{keyPrefix: prefix, targetKeyPrefix: fmt.Sprintf("%scontext.targets[%d].", prefix, j), target: t}
+			contexts = append(contexts, targetConfig)
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	if hasTargets {
+		// the targets replace the top-level context or the contexts array
+		config.ContextConfig = ContextConfig{}
+		config.Contexts = contexts
+	}
+	return nil
+}
+
+// contextExpansion records where the settings of a context that expandTargets created appear in the request file.
+type contextExpansion struct {
+	keyPrefix       string // the key path prefix of the request file context, such as contexts[1].
+	targetKeyPrefix string // the key path prefix of the target, such as contexts[1].context.targets[0]., for a target's context
+	target          target
+}
+
+// contextKeyPath returns the request file key path of a setting, such as context.target, of the context at the
+// specified position of GetContexts. The key path of a setting from a target refers to the targets list.
+func (c *Config) contextKeyPath(i int, key string) string {
+
+	ctx := c.GetContexts()[i]
+	if ctx.expansion == nil {
+		if len(c.Contexts) > 0 {
+			return fmt.Sprintf("contexts[%d].%s", i, key)
+		}
+		return key
+	}
+
+	expansion := ctx.expansion
+	if expansion.targetKeyPrefix != "" {
+		switch {
+		case key == "context.target":
+			return expansion.targetKeyPrefix + "url"
+		case key == "context.name" && expansion.target.Name != "":
+			return expansion.targetKeyPrefix + "name"
+		case strings.HasPrefix(key, "context.includeRegularExpressions") && len(expansion.target.IncludeRegularExpressions) > 0,
+			strings.HasPrefix(key, "context.excludeRegularExpressions") && len(expansion.target.ExcludeRegularExpressions) > 0:
+			return expansion.targetKeyPrefix + strings.TrimPrefix(key, "context.")
+		}
+	}
+	return expansion.keyPrefix + key
+}
+
+// reportSite identifies a site element of a ZAP XML report by its host and port attributes.
+type reportSite struct {
+	host string
+	port string
+}
+
+// reportSiteTargets returns the report sites of the contexts scanning targets, in target order, with the names of
+// the targets on each site.
+func reportSiteTargets(config *Config) ([]reportSite, map[reportSite][]string) {
+
+	sites := make([]reportSite, 0)
+	names := make(map[reportSite][]string)
+	for _, ctx := range config.GetContexts() {
+
+		if !ctx.isTarget {
+			continue
+		}
+
+		u, err := url.Parse(ctx.Context.Target)
+		if err != nil || u.Hostname() == "" {
+			continue
+		}
+
+		site := reportSite{host: u.Hostname(), port: u.Port()}
+		if site.port == "" {
+			site.port = "80"
+			if strings.EqualFold(u.Scheme, "https") {
+				site.port = "443"
+			}
+		}
+
+		if _, ok := names[site]; !ok {
+			sites = append(sites, site)
+		}
+		names[site] = append(names[site], ctx.Context.Name)
+	}
+	return sites, names
+}
+
+// reportTargetXslt returns an XSLT stylesheet that orders the sites of a ZAP XML report by target and adds a target
+// attribute naming the targets on each site. Sites that are not a target's site, such as a third-party host that
+// a target links to, follow the targets' sites.
+func reportTargetXslt(sites []reportSite, names map[reportSite][]string) string {
+
+	var targetSites bytes.Buffer
+	conditions := make([]string, len(sites))
+	for i, site := range sites {
+
+		// hosts and ports cannot contain quotes, so they are safe to use as XPath string literals
+		conditions[i] = fmt.Sprintf("(@host='%s' and @port='%s')", site.host, site.port)
+
+		var name bytes.Buffer
+		_ = xml.EscapeText(&name, []byte(strings.Join(names[site], ", ")))
+
+		fmt.Fprintf(&targetSites, `
+      <xsl:for-each select="site[%s]">
+        <xsl:copy>
+          <xsl:apply-templates select="@*"/>
+          <xsl:attribute name="target">%s</xsl:attribute>
+          <xsl:apply-templates select="node()"/>
+        </xsl:copy>
+      </xsl:for-each>`, conditions[i], name.String())
+	}
+
+	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<xsl:stylesheet xmlns:xsl="http://www.w3.org/1999/XSL/Transform" version="1.0">
+  <xsl:template match="@*|node()">
+    <xsl:copy>
+      <xsl:apply-templates select="@*|node()"/>
+    </xsl:copy>
+  </xsl:template>
+  <xsl:template match="OWASPZAPReport">
+    <xsl:copy>
+      <xsl:apply-templates select="@*|node()[not(self::site)]"/>%s
+      <xsl:apply-templates select="site[not(%s)]"/>
+    </xsl:copy>
+  </xsl:template>
+</xsl:stylesheet>`, targetSites.String(), strings.Join(conditions, " or "))
+}
+
+// GroupReportByTarget groups the sites of a ZAP XML report by the targets of the contexts' targets lists. It does
+// nothing when no context has a targets list.
+// It returns an error when a failure occurs.
+func GroupReportByTarget(xsltProgram string, outputFileName string, config *Config) error {
+
+	sites, names := reportSiteTargets(config)
+	if len(sites) == 0 {
+		return nil
+	}
+	return runXslt(xsltProgram, reportTargetXslt(sites, names), outputFileName)
+}
@@ -0,0 +1,142 @@
+package zap
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/codedx/codedx-add-ins/pkg/assert"
+)
+
+const targetsConfig = `
+[context]
+name = "Services"
+excludeRegularExpressions = [".*/logout.*"]
+targets = [
+  { name = "Orders", url = "https://orders.example.com/" },
+  { url = "http://localhost:8080/inventory", includeRegularExpressions = ["http://localhost:8080/inventory/.*"] },
+  { url = "http://localhost:8080/billing", excludeRegularExpressions = [".*/billing/admin.*"] },
+]
+
+[authentication]
+type = "none"
+`
+
+func TestParseConfigExpandsTargets(t *testing.T) {
+
+	cfg, err := ParseConfigReader(strings.NewReader(targetsConfig), "normal")
+	assert.NilError(t, err)
+	assert.NilError(t, cfg.Validate("normal"))
+
+	contexts := cfg.GetContexts()
+	assert.IntsAreEqual(t, 3, len(contexts))
+
+	assert.StringsAreEqual(t, "Orders", contexts[0].Context.Name)
+	assert.StringsAreEqual(t, "https://orders.example.com/", contexts[0].Context.Target)
+	assert.StringsAreEqual(t, "https://orders.example.com/.*", strings.Join(contexts[0].Context.IncludeRegularExpressions, ";"))
+	assert.StringsAreEqual(t, ".*/logout.*", strings.Join(contexts[0].Context.ExcludeRegularExpressions, ";"))
+
+	assert.StringsAreEqual(t, "Services-2", contexts[1].Context.Name)
+	assert.StringsAreEqual(t, "http://localhost:8080/inventory/.*", strings.Join(contexts[1].Context.IncludeRegularExpressions, ";"))
+
+	assert.StringsAreEqual(t, "Services-3", contexts[2].Context.Name)
+	assert.StringsAreEqual(t, ".*/billing/admin.*", strings.Join(contexts[2].Context.ExcludeRegularExpressions, ";"))
+	assert.IntsAreEqual(t, 2, contexts[2].index)
+}
+
+func TestParseConfigRejectsInvalidTargets(t *testing.T) {
+
+	_, err := ParseConfigReader(strings.NewReader(targetsConfig), "api")
+	assert.NotNil(t, err)
+	assert.StringsAreEqual(t, "context.targets: is supported by normal scans only", err.Error())
+
+	_, err = ParseConfigReader(strings.NewReader(`
+[context]
+target = "http://localhost/"
+targets = [{ name = "Missing" }]
+`), "normal")
+	assert.NotNil(t, err)
+	assert.StringsAreEqual(t, "context.targets: cannot be combined with context.target\ncontext.targets[0].url: is required", err.Error())
+}
+
+func TestParseConfigRejectsTargetsWithContextFile(t *testing.T) {
+
+	_, err := ParseConfigReader(strings.NewReader(`
+[context]
+contextFile = "app.context"
+targets = [{ url = "http://localhost/a" }, { url = "http://localhost/b" }]
+`), "normal")
+	assert.NotNil(t, err)
+	assert.StringsAreEqual(t, "context.targets: cannot be combined with context.contextFile", err.Error())
+}
+
+func TestValidateTargetsKeyPaths(t *testing.T) {
+
+	cfg, err := ParseConfigReader(strings.NewReader(`
+[[contexts]]
+[contexts.context]
+name = "Portal"
+target = "http://localhost/portal"
+
+[[contexts]]
+[contexts.context]
+name = "Services"
+targets = [
+  { name = "Portal", url = "http://localhost/orders" },
+  { url = "http://localhost/billing", includeRegularExpressions = ["http://localhost/billing/(.*"] },
+]
+[contexts.formAuthentication]
+formURL = "http://localhost/login"
+[contexts.authentication]
+type = "formAuthentication"
+`), "normal")
+	assert.NilError(t, err)
+
+	errs, ok := cfg.Validate("normal").(ValidationErrors)
+	assert.True(t, ok)
+
+	keys := make([]string, len(errs))
+	for i, err := range errs {
+		keys[i] = err.Key
+	}
+	assert.StringsAreEqual(t, strings.Join([]string{
+		"contexts[1].context.targets[0].name",
+		"contexts[1].formAuthentication.formUsernameFieldName",
+		"contexts[1].formAuthentication.formPasswordFieldName",
+		"contexts[1].context.targets[1].includeRegularExpressions[0]",
+	}, ";"), strings.Join(keys, ";"))
+}
+
+func TestReportTargetXslt(t *testing.T) {
+
+	cfg, err := ParseConfigReader(strings.NewReader(targetsConfig), "normal")
+	assert.NilError(t, err)
+
+	sites, names := reportSiteTargets(cfg)
+	assert.IntsAreEqual(t, 2, len(sites))
+	assert.StringsAreEqual(t, "443", sites[0].port)
+	assert.StringsAreEqual(t, "Services-2, Services-3", strings.Join(names[sites[1]], ", "))
+
+	xslt := reportTargetXslt(sites, names)
+	assert.True(t, strings.Contains(xslt, `site[(@host='orders.example.com' and @port='443')]`))
+	assert.True(t, strings.Contains(xslt, `<xsl:attribute name="target">Services-2, Services-3</xsl:attribute>`))
+	assert.True(t, strings.Contains(xslt, `site[not((@host='orders.example.com' and @port='443') or (@host='localhost' and @port='8080'))]`))
+
+	decoder := xml.NewDecoder(strings.NewReader(xslt))
+	for {
+		_, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		assert.NilError(t, err)
+	}
+}
+
+func TestGroupReportByTargetSkipsConfigWithoutTargets(t *testing.T) {
+
+	cfg := Config{}
+	cfg.Context.Target = "http://localhost/"
+
+	assert.NilError(t, GroupReportByTarget("unsupported", "report.xml", &cfg))
+}
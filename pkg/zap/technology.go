@@ -0,0 +1,65 @@
+package zap
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/zaproxy/zap-api-go/zap"
+)
+
+// configureContextTechnologies limits the context to the included technologies, when specified, and then removes the
+// excluded technologies so that ZAP skips scan rules that do not apply to the application's technology stack.
+func configureContextTechnologies(includeNames []string, excludeNames []string, contextName string, zap *zap.Interface) error {
+
+	if len(includeNames) == 0 && len(excludeNames) == 0 {
+		return nil
+	}
+
+	result, err := (*zap).Context().TechnologyList()
+	if err != nil {
+		return err
+	}
+
+	technologies, err := getZapStringListResult("techList", result)
+	if err != nil {
+		return err
+	}
+
+	if unknownNames := findUnknownTechnologies(technologies, includeNames, excludeNames); len(unknownNames) > 0 {
+		return fmt.Errorf("unable to find technologies %s; expected names such as Db.PostgreSQL or Language.Java", strings.Join(unknownNames, ", "))
+	}
+
+	if len(includeNames) > 0 {
+		if _, err := (*zap).Context().ExcludeAllContextTechnologies(contextName); err != nil {
+			return err
+		}
+		if _, err := (*zap).Context().IncludeContextTechnologies(contextName, strings.Join(includeNames, ",")); err != nil {
+			return err
+		}
+	}
+
+	if len(excludeNames) > 0 {
+		if _, err := (*zap).Context().ExcludeContextTechnologies(contextName, strings.Join(excludeNames, ",")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func findUnknownTechnologies(technologies []string, nameLists ...[]string) []string {
+
+	known := make(map[string]bool, len(technologies))
+	for _, technology := range technologies {
+		known[technology] = true
+	}
+
+	unknownNames := make([]string, 0)
+	for _, names := range nameLists {
+		for _, name := range names {
+			if !known[name] {
+				unknownNames = append(unknownNames, name)
+			}
+		}
+	}
+	return unknownNames
+}
@@ -0,0 +1,40 @@
+package zap
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/codedx/codedx-add-ins/pkg/assert"
+)
+
+func TestFindUnknownTechnologies(t *testing.T) {
+
+	technologies := []string{"Db", "Db.PostgreSQL", "Db.Oracle", "Language", "Language.Java"}
+
+	assert.IntsAreEqual(t, 0, len(findUnknownTechnologies(technologies, []string{"Db.PostgreSQL", "Language.Java"}, []string{"Db.Oracle"})))
+
+	unknownNames := findUnknownTechnologies(technologies, []string{"Db.PostgreSQL", "Postgres"}, []string{"Language.PHP"})
+	assert.IntsAreEqual(t, 2, len(unknownNames))
+	assert.StringsAreEqual(t, "Postgres", unknownNames[0])
+	assert.StringsAreEqual(t, "Language.PHP", unknownNames[1])
+}
+
+func TestApiScanContextIncludesTechnologies(t *testing.T) {
+
+	cfg, err := ParseConfigReader(strings.NewReader(`
+[context]
+target = "openapi.json"
+format = "openapi"
+includeTechnologies = ["Db.PostgreSQL"]
+`), "api")
+	assert.NilError(t, err)
+
+	assert.True(t, cfg.IsContextFileRequired())
+	assert.True(t, cfg.IsContextExportRequired())
+	assert.StringsAreEqual(t, ".*", strings.Join(cfg.Context.IncludeRegularExpressions, ";"))
+	assert.True(t, strings.HasSuffix(strings.Join(ApiScanArguments(cfg, "zap-api-scan.py", "wrk"), " "), "-n context.xml -S"))
+
+	cfg.Context.ContextFile = "context.xml"
+	cfg.Context.IncludeRegularExpressions = nil
+	assert.True(t, cfg.IsContextExportRequired())
+}
@@ -0,0 +1,142 @@
+package zap
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zaproxy/zap-api-go/zap"
+)
+
+const (
+	totpPlaceholder = "{%totp%}"
+	totpDigits      = 6
+	totpPeriod      = 30 * time.Second
+)
+
+// GenerateTOTP returns the RFC 6238 time-based one-time password for a base32-encoded secret at the specified time.
+// It returns an error if the secret cannot be decoded.
+func GenerateTOTP(secret string, t time.Time) (string, error) {
+
+	key, err := decodeTOTPSecret(secret)
+	if err != nil {
+		return "", err
+	}
+
+	counter := make([]byte, 8)
+	binary.BigEndian.PutUint64(counter, uint64(t.Unix()/int64(totpPeriod/time.Second)))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	code := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < totpDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", totpDigits, code%mod), nil
+}
+
+func decodeTOTPSecret(secret string) ([]byte, error) {
+	s := strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(secret), " ", ""))
+	s = strings.TrimRight(s, "=")
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode base32 TOTP secret: %s", err.Error())
+	}
+	return key, nil
+}
+
+// ConfigureTOTP replaces the {%totp%} placeholder with the current TOTP code for the specified credential. A ZAP
+// replacer rule replaces the placeholder in the bodies of login requests, such as login form data, JSON login
+// bodies, and Zest script requests, and script authentication parameters, which Zest scripts read as variables,
+// receive the code in place of the placeholder.
+// It returns an error if a failure occurs.
+func ConfigureTOTP(zap *zap.Interface, cfg *ContextConfig, ctx *Context, cred Credential) error {
+
+	code, err := GenerateTOTP(cred.TotpSecret, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if err := addReplacerRule(zap, "totp", "REQ_BODY_STR", totpPlaceholder, code, cfg.totpURLRegularExpression()); err != nil {
+		return err
+	}
+
+	if !cfg.UseScriptAuthentication() || cfg.UseContextFile() || !hasTOTPParameter(cfg.ScriptAuthentication.AuthenticationScriptParameters) {
+		return nil
+	}
+	if err := setScriptAuthenticationMethod(zap, ctx.ContextID, cfg.resourceName("authScript"),
+		totpParameters(cfg.ScriptAuthentication.AuthenticationScriptParameters, code)); err != nil {
+		return err
+	}
+	// setting the method discards the indicators that ConfigureContext set
+	return setAuthenticationIndicators(zap, cfg, ctx.ContextID)
+}
+
+// totpURLRegularExpression returns the regular expression limiting the {%totp%} replacer rule to the login URL for
+// form authentication, or to the target's site, where an authentication script sends its login requests, otherwise.
+func (c *ContextConfig) totpURLRegularExpression() string {
+
+	if c.UseFormAuthentication() && c.FormAuthentication.FormURL != "" {
+		return regexp.QuoteMeta(c.FormAuthentication.FormURL)
+	}
+
+	u, err := url.Parse(c.Context.Target)
+	if err != nil || u.Host == "" {
+		return regexp.QuoteMeta(c.Context.Target)
+	}
+	return fmt.Sprintf(`(?i)%s://%s([/?#].*)?`, regexp.QuoteMeta(u.Scheme), regexp.QuoteMeta(u.Host))
+}
+
+func hasTOTPParameter(params []scriptParameter) bool {
+	for _, p := range params {
+		if strings.Contains(p.Value, totpPlaceholder) {
+			return true
+		}
+	}
+	return false
+}
+
+// totpParameters returns script parameters with the {%totp%} placeholder replaced by a TOTP code.
+func totpParameters(params []scriptParameter, code string) []scriptParameter {
+	values := make([]scriptParameter, len(params))
+	for i, p := range params {
+		values[i] = scriptParameter{Name: p.Name, Value: strings.ReplaceAll(p.Value, totpPlaceholder, code)}
+	}
+	return values
+}
+
+// RefreshTOTP updates the {%totp%} placeholder value at the start of every TOTP period. It runs until a quit
+// message arrives and then removes the replacer rule.
+func RefreshTOTP(zap *zap.Interface, cfg *ContextConfig, ctx *Context, cred Credential, quit chan int, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	for {
+		wait := totpPeriod - time.Duration(time.Now().UnixNano()%int64(totpPeriod))
+
+		select {
+		case <-quit:
+			if _, err := (*zap).Replacer().RemoveRule("totp"); err != nil {
+				log.Println(err)
+			}
+			return
+		case <-time.After(wait):
+		}
+
+		if err := ConfigureTOTP(zap, cfg, ctx, cred); err != nil {
+			log.Printf("Unable to update TOTP code: %s", err.Error())
+		}
+	}
+}
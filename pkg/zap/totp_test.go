@@ -0,0 +1,85 @@
+package zap
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codedx/codedx-add-ins/pkg/assert"
+)
+
+// the RFC 6238 SHA1 test secret "12345678901234567890" in base32
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestGenerateTOTP(t *testing.T) {
+
+	code, err := GenerateTOTP(rfc6238Secret, time.Unix(59, 0))
+	assert.NilError(t, err)
+	assert.StringsAreEqual(t, "287082", code)
+
+	code, err = GenerateTOTP(rfc6238Secret, time.Unix(1111111109, 0))
+	assert.NilError(t, err)
+	assert.StringsAreEqual(t, "081804", code)
+
+	code, err = GenerateTOTP(rfc6238Secret, time.Unix(1234567890, 0))
+	assert.NilError(t, err)
+	assert.StringsAreEqual(t, "005924", code)
+}
+
+func TestGenerateTOTPWithFormattedSecret(t *testing.T) {
+
+	code, err := GenerateTOTP("gezd gnbv gy3t qojq gezd gnbv gy3t qojq", time.Unix(59, 0))
+	assert.NilError(t, err)
+	assert.StringsAreEqual(t, "287082", code)
+}
+
+func TestGenerateTOTPWithInvalidSecret(t *testing.T) {
+
+	_, err := GenerateTOTP("not-base32!", time.Unix(59, 0))
+	assert.NotNil(t, err)
+}
+
+func TestTOTPURLRegularExpression(t *testing.T) {
+
+	cfg := ContextConfig{}
+	cfg.Context.Target = "https://localhost:8443/app"
+	cfg.Authentication.Type = "formAuthentication"
+	cfg.FormAuthentication.FormURL = "https://localhost:8443/login?next=/"
+	assert.StringsAreEqual(t, `https://localhost:8443/login\?next=/`, cfg.totpURLRegularExpression())
+
+	cfg.Authentication.Type = "scriptAuthentication"
+	assert.StringsAreEqual(t, `(?i)https://localhost:8443([/?#].*)?`, cfg.totpURLRegularExpression())
+}
+
+func TestTOTPParameters(t *testing.T) {
+
+	params := []scriptParameter{{Name: "loginUrl", Value: "https://localhost/login"}, {Name: "otp", Value: "{%totp%}"}}
+	assert.True(t, hasTOTPParameter(params))
+	assert.False(t, hasTOTPParameter(params[:1]))
+
+	values := totpParameters(params, "123456")
+	assert.StringsAreEqual(t, "https://localhost/login", values[0].Value)
+	assert.StringsAreEqual(t, "123456", values[1].Value)
+	assert.StringsAreEqual(t, "{%totp%}", params[1].Value)
+}
+
+func TestConfigureTOTPRestoresAuthenticationIndicators(t *testing.T) {
+
+	cfg := ContextConfig{}
+	cfg.Context.Target = "http://localhost/"
+	cfg.Authentication.Type = "scriptAuthentication"
+	cfg.Authentication.LoginIndicatorRegex = `\QSign out\E`
+	cfg.Authentication.LoggedOutIndicatorRegex = `\QSign in\E`
+	cfg.ScriptAuthentication.AuthenticationScriptParameters = []scriptParameter{{Name: "otp", Value: totpPlaceholder}}
+
+	client, calls := newFakeZapClient(t, nil)
+	assert.NilError(t, ConfigureTOTP(client, &cfg, &Context{ContextID: "1"}, Credential{TotpSecret: rfc6238Secret}))
+
+	assert.StringsAreEqual(t, strings.Join([]string{
+		"replacer/action/removeRule",
+		"replacer/action/addRule",
+		"authentication/action/setAuthenticationMethod",
+		"authentication/action/setLoggedInIndicator",
+		"authentication/action/setLoggedOutIndicator",
+	}, ";"), strings.Join(calls(), ";"))
+}
@@ -0,0 +1,207 @@
+package zap
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+	"github.com/zaproxy/zap-api-go/zap"
+)
+
+// UserMetadata contains optional user settings read from a metadata file in the user's workflow secret directory.
+type UserMetadata struct {
+	Role                      string
+	StartURLs                 []string
+	ExcludeRegularExpressions []string
+}
+
+// userMetadataFileNames lists the supported metadata files in order of precedence.
+var userMetadataFileNames = []string{"metadata.toml", "metadata.json"}
+
+// readUserMetadata reads a user's metadata file from a workflow secret directory, returning empty metadata when
+// no metadata file exists.
+func readUserMetadata(directory string) (UserMetadata, error) {
+
+	var metadata UserMetadata
+	for _, name := range userMetadataFileNames {
+		metadataFile := filepath.Join(directory, name)
+		if _, err := os.Stat(metadataFile); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return metadata, err
+		}
+
+		v := viper.New()
+		v.SetConfigFile(metadataFile)
+		if err := v.ReadInConfig(); err != nil {
+			return metadata, fmt.Errorf("unable to read user metadata file %s: %s", metadataFile, err.Error())
+		}
+		if err := v.Unmarshal(&metadata); err != nil {
+			return metadata, fmt.Errorf("unable to read user metadata file %s: %s", metadataFile, err.Error())
+		}
+		break
+	}
+	return metadata, nil
+}
+
+// GetStartURLs returns the URLs where the user's spider and scan start, which default to the context target.
+func (u *User) GetStartURLs(target string) []string {
+	if len(u.Credential.Metadata.StartURLs) > 0 {
+		return u.Credential.Metadata.StartURLs
+	}
+	return []string{target}
+}
+
+// ExcludeUserURLs excludes the user's extra exclude regular expressions from the spider and active scan.
+// It returns a function that restores the previous exclusions and an error if a failure occurs.
+func ExcludeUserURLs(zap *zap.Interface, user User) (func() error, error) {
+
+	exps := user.Credential.Metadata.ExcludeRegularExpressions
+	if len(exps) == 0 {
+		return func() error { return nil }, nil
+	}
+
+	spiderResult, err := (*zap).Spider().ExcludedFromScan()
+	if err != nil {
+		return nil, err
+	}
+	spiderExclusions, err := getZapStringListResult("excludedFromScan", spiderResult)
+	if err != nil {
+		return nil, err
+	}
+
+	scanResult, err := (*zap).Ascan().ExcludedFromScan()
+	if err != nil {
+		return nil, err
+	}
+	scanExclusions, err := getZapStringListResult("excludedFromScan", scanResult)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range exps {
+		if _, err := (*zap).Spider().ExcludeFromScan(e); err != nil {
+			return nil, err
+		}
+		if _, err := (*zap).Ascan().ExcludeFromScan(e); err != nil {
+			return nil, err
+		}
+	}
+
+	return func() error {
+		if _, err := (*zap).Spider().ClearExcludedFromScan(); err != nil {
+			return err
+		}
+		for _, e := range spiderExclusions {
+			if _, err := (*zap).Spider().ExcludeFromScan(e); err != nil {
+				return err
+			}
+		}
+		if _, err := (*zap).Ascan().ClearExcludedFromScan(); err != nil {
+			return err
+		}
+		for _, e := range scanExclusions {
+			if _, err := (*zap).Ascan().ExcludeFromScan(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	}, nil
+}
+
+// CountAlerts returns the number of alerts ZAP has raised.
+// It returns an error if a failure occurs.
+func CountAlerts(zap *zap.Interface) (int, error) {
+	result, err := (*zap).Alert().NumberOfAlerts("", "")
+	if err != nil {
+		return 0, err
+	}
+	return getZapIntResult("numberOfAlerts", result)
+}
+
+// LabelAlerts adds the user's role label to the other information of alerts raised after the specified number of
+// alerts, which attaches the label to the alerts from the user's spider and scan.
+// It returns an error if a failure occurs.
+func LabelAlerts(zap *zap.Interface, user User, firstAlert int) error {
+
+	role := user.Credential.Metadata.Role
+	if role == "" {
+		return nil
+	}
+
+	result, err := (*zap).Alert().Alerts("", strconv.Itoa(firstAlert), "", "")
+	if err != nil {
+		return err
+	}
+	alerts, err := getZapResult("alerts", result)
+	if err != nil {
+		return err
+	}
+
+	list, _ := alerts.([]interface{})
+	for _, a := range list {
+		alert, ok := a.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if err := labelAlert(zap, alert, fmt.Sprintf("Role: %s", role)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func labelAlert(zap *zap.Interface, alert map[string]interface{}, label string) error {
+
+	field := func(name string) string {
+		v, _ := alert[name].(string)
+		return v
+	}
+
+	otherInfo := field("other")
+	if strings.Contains(otherInfo, label) {
+		return nil
+	}
+	if otherInfo != "" {
+		otherInfo += "\n\n"
+	}
+	otherInfo += label
+
+	result, err := (*zap).Alert().UpdateAlert(field("id"),
+		field("name"),
+		alertRiskIDs[field("risk")],
+		alertConfidenceIDs[field("confidence")],
+		field("description"),
+		field("param"),
+		field("attack"),
+		otherInfo,
+		field("solution"),
+		field("reference"),
+		field("evidence"),
+		field("cweid"),
+		field("wascid"))
+	if err != nil {
+		return err
+	}
+	_, err = getZapResult("Result", result)
+	return err
+}
+
+var alertRiskIDs = map[string]string{
+	"Informational": "0",
+	"Low":           "1",
+	"Medium":        "2",
+	"High":          "3",
+}
+
+var alertConfidenceIDs = map[string]string{
+	"False Positive": "0",
+	"Low":            "1",
+	"Medium":         "2",
+	"High":           "3",
+	"Confirmed":      "4",
+}
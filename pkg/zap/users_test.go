@@ -0,0 +1,47 @@
+package zap
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/codedx/codedx-add-ins/pkg/assert"
+)
+
+func TestReadUserMetadataTOML(t *testing.T) {
+
+	workDirectory := t.TempDir()
+	writeSecret(t, workDirectory, "reader", "metadata.toml", `
+role = "read-only"
+startURLs = ["http://localhost/reports"]
+excludeRegularExpressions = ["http://localhost/.*/delete.*"]
+`)
+
+	metadata, err := readUserMetadata(workDirectory + "/workflow-secrets/reader")
+	assert.NilError(t, err)
+	assert.StringsAreEqual(t, "read-only", metadata.Role)
+	assert.StringsAreEqual(t, "http://localhost/reports", strings.Join(metadata.StartURLs, ";"))
+	assert.StringsAreEqual(t, "http://localhost/.*/delete.*", strings.Join(metadata.ExcludeRegularExpressions, ";"))
+}
+
+func TestReadUserMetadataJSON(t *testing.T) {
+
+	workDirectory := t.TempDir()
+	writeSecret(t, workDirectory, "admin", "metadata.json", `{"role": "admin", "startURLs": ["http://localhost/admin"]}`)
+
+	metadata, err := readUserMetadata(workDirectory + "/workflow-secrets/admin")
+	assert.NilError(t, err)
+	assert.StringsAreEqual(t, "admin", metadata.Role)
+
+	user := User{Credential: Credential{Metadata: metadata}}
+	assert.StringsAreEqual(t, "http://localhost/admin", strings.Join(user.GetStartURLs("http://localhost"), ";"))
+}
+
+func TestReadUserMetadataMissing(t *testing.T) {
+
+	metadata, err := readUserMetadata(t.TempDir())
+	assert.NilError(t, err)
+	assert.EmptyString(t, metadata.Role)
+
+	user := User{Credential: Credential{Metadata: metadata}}
+	assert.StringsAreEqual(t, "http://localhost", strings.Join(user.GetStartURLs("http://localhost"), ";"))
+}
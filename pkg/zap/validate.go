@@ -0,0 +1,246 @@
+package zap
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+)
+
+// ValidationError describes a problem with the value at a key path of the scan request file, such as context.target
+// or contexts[1].formAuthentication.formURL.
+type ValidationError struct {
+	Key     string
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Key, e.Message)
+}
+
+// ValidationErrors holds every problem found in a scan request file.
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+func (e *ValidationErrors) add(key string, format string, v ...interface{}) {
+	*e = append(*e, ValidationError{Key: key, Message: fmt.Sprintf(format, v...)})
+}
+
+// authenticationTypes lists the values that authentication.type accepts.
+var authenticationTypes = map[string]bool{
+	"":                     true,
+	"none":                 true,
+	"headerAuthentication": true,
+	"oauth2Authentication": true,
+	"formAuthentication":   true,
+	"scriptAuthentication": true,
+}
+
+// apiScanFormats lists the values that context.format accepts, which are the zap-api-scan target formats.
+var apiScanFormats = map[string]bool{
+	"openapi": true,
+	"soap":    true,
+	"graphql": true,
+}
+
+// Validate returns ValidationErrors listing every problem with the configuration for the specified scan mode, or
+// nil when the configuration is valid.
+func (c *Config) Validate(scanMode string) error {
+
+	var errs ValidationErrors
+
+	if !IsNormalScan(scanMode) && !IsApiScan(scanMode) {
+		errs.add("scanMode", "unknown scan mode %q; expected normal or api", scanMode)
+		return errs
+	}
+
+	if len(c.Contexts) > 0 {
+		// the top-level context cannot be combined with a contexts array
+		if c.Context.Target != "" {
+			errs.add("context.target", "cannot be combined with a contexts array")
+		}
+		if IsApiScan(scanMode) && len(c.Contexts) > 1 {
+			errs.add("contexts", "an API scan supports only one context")
+		}
+		names := make(map[string]bool)
+		for i, ctx := range c.Contexts {
+			if names[ctx.Context.Name] {
+				errs.add(c.contextKeyPath(i, "context.name"), "duplicate context name %q", ctx.Context.Name)
+			}
+			names[ctx.Context.Name] = true
+		}
+	}
+
+	// the contexts of a targets list share the settings of the context listing them, so they can find the same problem
+	reported := make(map[ValidationError]bool)
+	for i, ctx := range c.GetContexts() {
+
+		var contextErrs ValidationErrors
+		ctx.validate(scanMode, &contextErrs)
+
+		// a context target must be on the scope allowlist
+		if c.Scope.IsEnabled() && !c.Scope.isAllowedURL(ctx.Context.Target) {
+			contextErrs.add("context.target", "host is not on the scope.allowedHosts list")
+		}
+		if err := c.checkActiveScanTarget(ctx); err != nil {
+			contextErrs.add("context.target", "%s", err.Error())
+		}
+
+		// report the key paths of the request file, which differ for the contexts of a targets list
+		for _, err := range contextErrs {
+			err.Key = c.contextKeyPath(i, err.Key)
+			if !reported[err] {
+				reported[err] = true
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	for i, keyPath := range c.ReplaceBaseLists {
+		keys := strings.Split(keyPath, ".")
+		if !mergedBaseLists[strings.ToLower(keys[len(keys)-1])] {
+			errs.add(fmt.Sprintf("replaceBaseLists[%d]", i), "%q is not an includeRegularExpressions or excludeRegularExpressions key path", keyPath)
+		}
+	}
+
+	if c.ReportOptions.MinRiskThreshold < 0 || c.ReportOptions.MinRiskThreshold > 3 {
+		errs.add("reportOptions.minRiskThreshold", "must be between 0 (informational) and 3 (high)")
+	}
+	if c.ReportOptions.MinConfThreshold < 0 || c.ReportOptions.MinConfThreshold > 4 {
+		errs.add("reportOptions.minConfThreshold", "must be between 0 (false positive) and 4 (confirmed)")
+	}
+
+	if IsNormalScan(scanMode) {
+		// disallow api-scan only fields
+		if len(c.ScanOptions.ApiScanOptions) > 0 {
+			errs.add("scanOptions.apiScanOptions", "is supported by API scans only")
+		}
+		if c.ScanOptions.ApiScanConfigContent != "" {
+			errs.add("scanOptions.apiScanConfigContent", "is supported by API scans only")
+		}
+		if c.ScanOptions.Mode == "safe" {
+			errs.add("scanOptions.mode", "safe mode forbids the spider that normal scans run")
+		}
+	}
+
+	if c.ScanOptions.Mode != "" && !zapModes[c.ScanOptions.Mode] {
+		errs.add("scanOptions.mode", "unknown mode %q; expected safe, protected, or standard", c.ScanOptions.Mode)
+	} else if c.ScanOptions.Mode == "safe" && c.ScanOptions.RunActiveScan {
+		errs.add("scanOptions.mode", "safe mode forbids the active scan that scanOptions.runActiveScan requests")
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// IsValid reports whether Validate finds no problems with the configuration.
+func (c *Config) IsValid(scanMode string) bool {
+	return c.Validate(scanMode) == nil
+}
+
+func (c *ContextConfig) validate(scanMode string, errs *ValidationErrors) {
+
+	if c.Context.Name == "" {
+		errs.add("context.name", "is required")
+	}
+	if c.Context.Target == "" {
+		errs.add("context.target", "is required")
+	}
+
+	validateRegularExpressions("context.includeRegularExpressions", c.Context.IncludeRegularExpressions, errs)
+	validateRegularExpressions("context.excludeRegularExpressions", c.Context.ExcludeRegularExpressions, errs)
+
+	for i, pattern := range c.Context.DataDrivenNodes {
+		if err := validateDataDrivenNode(pattern); err != nil {
+			errs.add(fmt.Sprintf("context.dataDrivenNodes[%d]", i), "%s", err.Error())
+		}
+	}
+
+	if !authenticationTypes[c.Authentication.Type] {
+		errs.add("authentication.type", "unknown authentication type %q; expected none, headerAuthentication, oauth2Authentication, formAuthentication, or scriptAuthentication", c.Authentication.Type)
+	}
+
+	if c.UseFormAuthentication() && !c.UseContextFile() {
+		if c.FormAuthentication.FormURL == "" {
+			errs.add("formAuthentication.formURL", "is required for form authentication")
+		}
+		if c.FormAuthentication.FormUsernameFieldName == "" {
+			errs.add("formAuthentication.formUsernameFieldName", "is required for form authentication")
+		}
+		if c.FormAuthentication.FormPasswordFieldName == "" {
+			errs.add("formAuthentication.formPasswordFieldName", "is required for form authentication")
+		}
+	}
+
+	if c.UseScriptAuthentication() && !c.UseContextFile() && c.ScriptAuthentication.AuthenticationScriptContent == "" {
+		errs.add("scriptAuthentication.authenticationScriptContent", "or authenticationScriptFile is required for script authentication")
+	}
+
+	if c.UseOAuth2Authentication() && c.OAuth2Authentication.TokenURL == "" {
+		errs.add("oauth2Authentication.tokenURL", "is required for OAuth 2.0 authentication")
+	}
+
+	if strings.HasPrefix(c.CredentialSources.EnvironmentPrefix, ConfigOverrideEnvironmentPrefix) {
+		errs.add("credentialSources.environmentPrefix", "cannot begin with %s, which is reserved for request file overrides", ConfigOverrideEnvironmentPrefix)
+	}
+
+	for i, filter := range c.AlertFilters {
+		if filter.RuleID <= 0 {
+			errs.add(fmt.Sprintf("alertFilters[%d].ruleId", i), "must be a ZAP scan rule ID")
+		}
+		if _, ok := filter.newLevel(); !ok {
+			errs.add(fmt.Sprintf("alertFilters[%d].newRisk", i), "unknown risk %q; expected False Positive, Informational, Low, Medium, or High", filter.NewRisk)
+		}
+	}
+
+	if IsNormalScan(scanMode) {
+		// disallow api-scan only fields
+		if c.Context.Format != "" {
+			errs.add("context.format", "is supported by API scans only")
+		}
+		if c.Context.OpenApiHostnameOverride != "" {
+			errs.add("context.openApiHostnameOverride", "is supported by API scans only")
+		}
+		return
+	}
+
+	// require format be defined and disallow normal-scan only fields
+	if c.Context.Format == "" {
+		errs.add("context.format", "is required for API scans")
+	} else if !apiScanFormats[c.Context.Format] {
+		errs.add("context.format", "unknown format %q; expected openapi, soap, or graphql", c.Context.Format)
+	}
+	if c.Authentication.ForcedUserMode {
+		errs.add("authentication.forcedUserMode", "is supported by normal scans only")
+	}
+	if c.Authentication.ExcludeLogoutLinks {
+		errs.add("authentication.excludeLogoutLinks", "is supported by normal scans only")
+	}
+	if len(c.Context.ImportURLs) > 0 {
+		errs.add("context.importURLs", "is supported by normal scans only")
+	}
+}
+
+// validateRegularExpressions reports regular expressions that cannot be parsed. ZAP uses Java regular expressions,
+// so lookaround assertions, which Go does not support, are accepted.
+func validateRegularExpressions(key string, exps []string, errs *ValidationErrors) {
+	for i, e := range exps {
+		if _, err := regexp.Compile(e); err != nil {
+			var syntaxErr *syntax.Error
+			if errors.As(err, &syntaxErr) && syntaxErr.Code == syntax.ErrInvalidPerlOp {
+				continue
+			}
+			errs.add(fmt.Sprintf("%s[%d]", key, i), "invalid regular expression: %s", err.Error())
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package zap
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/codedx/codedx-add-ins/pkg/assert"
+)
+
+func validationErrorKeys(t *testing.T, err error) []string {
+	var errs ValidationErrors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected ValidationErrors, got %v", err)
+	}
+	keys := make([]string, len(errs))
+	for i, e := range errs {
+		keys[i] = e.Key
+	}
+	return keys
+}
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+
+	cfg := Config{}
+	cfg.Context.Name = "Context"
+	cfg.Context.Format = "openapi"
+	cfg.Context.IncludeRegularExpressions = []string{"http://localhost/.*", "http://localhost/(.*"}
+	cfg.Context.ExcludeRegularExpressions = []string{"http://localhost/(?!api).*"}
+	cfg.Authentication.Type = "formAuthentication"
+	cfg.FormAuthentication.FormURL = "http://localhost/login"
+	cfg.ReportOptions.MinRiskThreshold = 4
+	cfg.ScanOptions.ApiScanOptions = []string{"-d"}
+
+	keys := validationErrorKeys(t, cfg.Validate("normal"))
+	assert.IntsAreEqual(t, 7, len(keys))
+	assert.StringsAreEqual(t, "context.target", keys[0])
+	assert.StringsAreEqual(t, "context.includeRegularExpressions[1]", keys[1])
+	assert.StringsAreEqual(t, "formAuthentication.formUsernameFieldName", keys[2])
+	assert.StringsAreEqual(t, "formAuthentication.formPasswordFieldName", keys[3])
+	assert.StringsAreEqual(t, "context.format", keys[4])
+	assert.StringsAreEqual(t, "reportOptions.minRiskThreshold", keys[5])
+	assert.StringsAreEqual(t, "scanOptions.apiScanOptions", keys[6])
+}
+
+func TestValidateContextsKeyPaths(t *testing.T) {
+
+	cfg := Config{}
+	cfg.Contexts = []ContextConfig{{}, {}}
+	cfg.Contexts[0].Context.Name = "Context"
+	cfg.Contexts[0].Context.Target = "http://localhost"
+	cfg.Contexts[1].Context.Name = "Context"
+	cfg.Contexts[1].Context.Target = "http://localhost/admin"
+	cfg.Contexts[1].Authentication.Type = "basicAuthentication"
+
+	keys := validationErrorKeys(t, cfg.Validate("normal"))
+	assert.IntsAreEqual(t, 2, len(keys))
+	assert.StringsAreEqual(t, "contexts[1].context.name", keys[0])
+	assert.StringsAreEqual(t, "contexts[1].authentication.type", keys[1])
+
+	cfg.Contexts[1].Context.Name = "Admin"
+	cfg.Contexts[1].Authentication.Type = "none"
+	assert.NilError(t, cfg.Validate("normal"))
+	assert.NotNil(t, cfg.Validate("unknown"))
+}
+
+func TestValidateApiScanFormat(t *testing.T) {
+
+	cfg := Config{}
+	cfg.Context.Name = "Context"
+	cfg.Context.Target = "openapi.json"
+	cfg.Context.Format = "wsdl"
+
+	keys := validationErrorKeys(t, cfg.Validate("api"))
+	assert.IntsAreEqual(t, 1, len(keys))
+	assert.StringsAreEqual(t, "context.format", keys[0])
+
+	cfg.Context.Format = "soap"
+	assert.NilError(t, cfg.Validate("api"))
+}
+
+func TestValidateReplaceBaseLists(t *testing.T) {
+
+	cfg := Config{}
+	cfg.Context.Name = "Context"
+	cfg.Context.Target = "http://localhost"
+	cfg.ReplaceBaseLists = []string{"context.excludeRegularExpressions", "alertFilters"}
+
+	keys := validationErrorKeys(t, cfg.Validate("normal"))
+	assert.IntsAreEqual(t, 1, len(keys))
+	assert.StringsAreEqual(t, "replaceBaseLists[1]", keys[0])
+}
@@ -11,6 +11,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 	"sync"
@@ -33,7 +34,7 @@ type User struct {
 }
 
 // RunZap starts the ZAP program to make its API available via the specified key.
-func RunZap(zapPath string, apiKey string, waitTime time.Duration, stdoutWriter io.Writer, stderrWriter io.Writer, ready chan string, quit chan int, wg *sync.WaitGroup) {
+func RunZap(zapPath string, apiKey string, waitTime time.Duration, stdoutWriter io.Writer, stderrWriter io.Writer, zapOptions []string, ready chan string, quit chan int, wg *sync.WaitGroup) {
 	defer wg.Done()
 
 	var zapStartArgs []string
@@ -47,6 +48,7 @@ func RunZap(zapPath string, apiKey string, waitTime time.Duration, stdoutWriter
 		zapStartArgs = append(zapStartArgs, "-XX:MaxRAMPercentage=75.0", "-jar", zapPath)
 	}
 	zapStartArgs = append(zapStartArgs, "-daemon", "-config", "api.key=api-key")
+	zapStartArgs = append(zapStartArgs, zapOptions...)
 
 	log.Printf("Starting ZAP: %s %s", zapStartPath, strings.Join(zapStartArgs, " "))
 	cmd := exec.Command(zapStartPath, zapStartArgs...)
@@ -114,7 +116,11 @@ func MakeClient(apiKey string) (*zap.Interface, error) {
 
 // ConfigureContext defines a ZAP context, an authentication approach, and a list of users.
 // It returns a Context and an error if a failure occurs.
-func ConfigureContext(zap *zap.Interface, cfg *Config, authScriptFile string) (Context, error) {
+func ConfigureContext(zap *zap.Interface, cfg *ContextConfig, authScriptFile string) (Context, error) {
+
+	if cfg.UseContextFile() {
+		return importContext(zap, cfg, authScriptFile)
+	}
 
 	var ctx Context
 
@@ -137,6 +143,23 @@ func ConfigureContext(zap *zap.Interface, cfg *Config, authScriptFile string) (C
 		return ctx, err
 	}
 
+	ctx.ContextID, err = restructureContext(zap, cfg, ctx.ContextID)
+	if err != nil {
+		return ctx, err
+	}
+
+	if err := addAntiCrossSiteRequestForgeryTokens(cfg.Context.AntiCrossSiteRequestForgeryTokenNames, zap); err != nil {
+		return ctx, err
+	}
+
+	if err := configureContextTechnologies(cfg.Context.IncludeTechnologies, cfg.Context.ExcludeTechnologies, cfg.Context.Name, zap); err != nil {
+		return ctx, err
+	}
+
+	if err := addAlertFilters(cfg.AlertFilters, zap, &ctx); err != nil {
+		return ctx, err
+	}
+
 	if !cfg.IsContextAuthRequired() {
 		return ctx, nil
 	}
@@ -153,13 +176,15 @@ func ConfigureContext(zap *zap.Interface, cfg *Config, authScriptFile string) (C
 
 	if cfg.UseScriptAuthentication() {
 
-		if err := configureScriptAuthentication(cfg.ScriptAuthentication, authScriptFile, zap, &ctx); err != nil {
+		if err := configureScriptAuthentication(cfg.ScriptAuthentication, cfg.resourceName("authScript"), authScriptFile, zap, &ctx); err != nil {
 			return ctx, err
 		}
-		credentialString = "Password=%s&Username=%s&type=GenericAuthenticationCredentials"
+		credentialString = fmt.Sprintf("%s=%%s&%s=%%s&type=GenericAuthenticationCredentials",
+			url.QueryEscape(cfg.ScriptAuthentication.PasswordParameterName),
+			url.QueryEscape(cfg.ScriptAuthentication.UsernameParameterName))
 	}
 
-	if _, err := (*zap).Authentication().SetLoggedInIndicator(ctx.ContextID, cfg.Authentication.LoginIndicatorRegex); err != nil {
+	if err := setAuthenticationIndicators(zap, cfg, ctx.ContextID); err != nil {
 		return ctx, err
 	}
 
@@ -170,6 +195,87 @@ func ConfigureContext(zap *zap.Interface, cfg *Config, authScriptFile string) (C
 	return ctx, nil
 }
 
+// ConfigureHeaderAuthentication adds ZAP replacer rules that include the configured authentication header, using
+// the header-value secret, with every request sent by the spider and active scan. The header is limited to the
+// authHeaderSite when one is specified.
+// It returns an error if a failure occurs.
+func ConfigureHeaderAuthentication(zap *zap.Interface, cfg *ContextConfig) error {
+
+	if !cfg.IsAuthenticationEnabled() || !cfg.UseHeaderAuthentication() {
+		return nil
+	}
+
+	return setAuthenticationHeader(zap, cfg.resourceName("authHeader"), cfg.HeaderAuthentication.AuthHeaderName, cfg.HeaderAuthentication.AuthHeaderSite, cfg.GetCredentials()[0].Password)
+}
+
+// ClearAuthenticationHeader removes the replacer rule that includes the context's authentication header.
+// It returns an error if a failure occurs.
+func ClearAuthenticationHeader(zap *zap.Interface, cfg *ContextConfig) error {
+	_, err := (*zap).Replacer().RemoveRule(cfg.resourceName("authHeader"))
+	return err
+}
+
+// setAuthenticationHeader adds or replaces the replacer rule that includes an authentication header, limiting
+// the header to the specified site when one is provided.
+func setAuthenticationHeader(zap *zap.Interface, ruleName string, headerName string, site string, value string) error {
+
+	if headerName == "" {
+		headerName = "Authorization"
+	}
+
+	return addReplacerRule(zap, ruleName, "REQ_HEADER", headerName, value, authenticationHeaderURLRegex(site))
+}
+
+// authenticationHeaderURLRegex returns the regular expression limiting an authentication header to a site, or an
+// empty string when there is no site.
+func authenticationHeaderURLRegex(site string) string {
+	if site == "" {
+		return ""
+	}
+	return fmt.Sprintf("^https?://%s([:/?#].*)?$", regexp.QuoteMeta(site))
+}
+
+// zapRequester provides access to ZAP API parameters that the generated client does not expose.
+type zapRequester interface {
+	Request(path string, queryParams map[string]string) (map[string]interface{}, error)
+}
+
+// addReplacerRule adds an enabled replacer rule that applies to requests from every initiator. A non-empty
+// urlRegex limits the rule to matching URLs.
+func addReplacerRule(zap *zap.Interface, description string, matchType string, matchString string, replacement string, urlRegex string) error {
+
+	// an existing rule with the same description prevents a new one from being added
+	if _, err := (*zap).Replacer().RemoveRule(description); err != nil {
+		return err
+	}
+
+	var result map[string]interface{}
+	var err error
+	if urlRegex == "" {
+		result, err = (*zap).Replacer().AddRule(description, "true", matchType, "false", matchString, replacement, "")
+	} else {
+		// the generated client's AddRule does not include the url parameter
+		requester, ok := (*zap).(zapRequester)
+		if !ok {
+			return errors.New("unable to add a replacer rule with a URL using the ZAP API client")
+		}
+		result, err = requester.Request("replacer/action/addRule/", map[string]string{
+			"description": description,
+			"enabled":     "true",
+			"matchType":   matchType,
+			"matchRegex":  "false",
+			"matchString": matchString,
+			"replacement": replacement,
+			"url":         urlRegex,
+		})
+	}
+	if err != nil {
+		return err
+	}
+	_, err = getZapResult("Result", result)
+	return err
+}
+
 func addContextIncludes(exps []string, contextName string, zap *zap.Interface) error {
 	for _, e := range exps {
 		if len(e) <= 0 {
@@ -200,15 +306,28 @@ func configureFormsAuthentication(formAuth formAuthentication, zap *zap.Interfac
 		formAuth.FormUsernameFieldName,
 		formAuth.FormPasswordFieldName)
 
-	antiCrossSiteRequestForgery := formAuth.FormAntiCrossSiteRequestForgeryFieldName
-	if len(antiCrossSiteRequestForgery) > 0 {
-		loginRequestData += fmt.Sprintf("&%s={%%token%%}", antiCrossSiteRequestForgery)
-
-		// The antiCrossSiteRequestForgery value may not be included in ZAP's default list, so
-		// add it now - adding duplicate tokens appears to be a no-op.
-		if _, err := (*zap).Acsrf().AddOptionToken(antiCrossSiteRequestForgery); err != nil {
+	antiCrossSiteRequestForgeryNames := formAuth.antiCrossSiteRequestForgeryFieldNames()
+	if formAuth.DiscoverAntiCrossSiteRequestForgeryFields {
+		discoveredNames, err := DiscoverAntiCrossSiteRequestForgeryFields(formAuth.formPageURL())
+		if err != nil {
 			return err
 		}
+		for _, name := range discoveredNames {
+			log.Printf("Discovered anti-CSRF token field %s at %s", name, formAuth.formPageURL())
+		}
+		if len(discoveredNames) == 0 {
+			log.Printf("Discovered no anti-CSRF token fields at %s", formAuth.formPageURL())
+		}
+		antiCrossSiteRequestForgeryNames = appendUnique(antiCrossSiteRequestForgeryNames, discoveredNames...)
+	}
+
+	for _, antiCrossSiteRequestForgery := range antiCrossSiteRequestForgeryNames {
+		loginRequestData += fmt.Sprintf("&%s={%%token%%}", antiCrossSiteRequestForgery)
+	}
+
+	// The anti-CSRF field names may not be included in ZAP's default list, so add them now
+	if err := addAntiCrossSiteRequestForgeryTokens(antiCrossSiteRequestForgeryNames, zap); err != nil {
+		return err
 	}
 
 	extraPostData := formAuth.FormExtraPostData
@@ -228,7 +347,42 @@ func configureFormsAuthentication(formAuth formAuthentication, zap *zap.Interfac
 	return err
 }
 
-func configureScriptAuthentication(scriptAuth scriptAuthentication, authScriptFile string, zap *zap.Interface, ctx *Context) error {
+func configureScriptAuthentication(scriptAuth scriptAuthentication, scriptName string, authScriptFile string, zap *zap.Interface, ctx *Context) error {
+
+	if err := loadAuthenticationScriptFile(scriptAuth, scriptName, authScriptFile, zap); err != nil {
+		return err
+	}
+
+	return setScriptAuthenticationMethod(zap, ctx.ContextID, scriptName, scriptAuth.AuthenticationScriptParameters)
+}
+
+// setAuthenticationIndicators sets the logged-in and logged-out indicators of a context's authentication method,
+// which ZAP discards whenever the authentication method is set.
+func setAuthenticationIndicators(zap *zap.Interface, cfg *ContextConfig, contextID string) error {
+
+	if _, err := (*zap).Authentication().SetLoggedInIndicator(contextID, cfg.Authentication.LoginIndicatorRegex); err != nil {
+		return err
+	}
+
+	if cfg.Authentication.LoggedOutIndicatorRegex != "" {
+		if _, err := (*zap).Authentication().SetLoggedOutIndicator(contextID, cfg.Authentication.LoggedOutIndicatorRegex); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func setScriptAuthenticationMethod(zap *zap.Interface, contextID string, scriptName string, params []scriptParameter) error {
+	_, err := (*zap).Authentication().SetAuthenticationMethod(contextID,
+		"scriptBasedAuthentication",
+		"scriptName="+url.QueryEscape(scriptName)+encodeParametersSuffix(params))
+
+	return err
+}
+
+// loadAuthenticationScriptFile writes the authentication script to a file, which is temporary unless authScriptFile
+// is specified, and loads it into ZAP with the specified name.
+func loadAuthenticationScriptFile(scriptAuth scriptAuthentication, scriptName string, authScriptFile string, zap *zap.Interface) error {
 	var xf *os.File
 	var err error
 	if authScriptFile == "" {
@@ -240,7 +394,7 @@ func configureScriptAuthentication(scriptAuth scriptAuthentication, authScriptFi
 			if err := xf.Close(); err != nil {
 				log.Println(err)
 			}
-			if err := os.Remove(xf.Name()); err != nil {
+			if err := SecureDelete(xf.Name()); err != nil {
 				log.Println(err)
 			}
 		}()
@@ -255,17 +409,26 @@ func configureScriptAuthentication(scriptAuth scriptAuthentication, authScriptFi
 		return err
 	}
 
-	if _, err = (*zap).Script().Load("authScript", "authentication", "Mozilla Zest", xf.Name(), "", ""); err != nil {
+	result, err := (*zap).Script().Load(scriptName, "authentication", scriptAuth.AuthenticationScriptEngine, xf.Name(), "", "")
+	if err != nil {
+		return err
+	}
+	if _, err := getZapResult("Result", result); err != nil {
 		return err
 	}
-
-	_, err = (*zap).Authentication().SetAuthenticationMethod(ctx.ContextID,
-		"scriptBasedAuthentication",
-		"scriptName=authScript")
 
 	log.Println("Created /zap/wrk/authScript")
 
-	return err
+	return nil
+}
+
+// encodeParametersSuffix returns parameters as a query string suffix that preserves their order.
+func encodeParametersSuffix(params []scriptParameter) string {
+	var sb strings.Builder
+	for _, p := range params {
+		sb.WriteString(fmt.Sprintf("&%s=%s", url.QueryEscape(p.Name), url.QueryEscape(p.Value)))
+	}
+	return sb.String()
 }
 
 func addUser(zap *zap.Interface, contextID string, username string, password string, credentialString string) (string, error) {
@@ -280,25 +443,28 @@ func addUser(zap *zap.Interface, contextID string, username string, password str
 	}
 	userIDString := strconv.Itoa(userID)
 
+	if err := setUserCredentials(zap, contextID, userIDString, username, password, credentialString); err != nil {
+		return "", err
+	}
+	return userIDString, nil
+}
+
+func setUserCredentials(zap *zap.Interface, contextID string, userID string, username string, password string, credentialString string) error {
+
 	passwordEncoded := url.QueryEscape(password)
 	usernameEncoded := url.QueryEscape(username)
 	authConfigParams := fmt.Sprintf(credentialString, passwordEncoded, usernameEncoded)
 
-	_, err = (*zap).Users().SetAuthenticationCredentials(contextID, userIDString, authConfigParams)
-
-	if err != nil {
-		return "", err
-	}
-
-	_, err = (*zap).Users().SetUserEnabled(contextID, userIDString, "True")
+	_, err := (*zap).Users().SetAuthenticationCredentials(contextID, userID, authConfigParams)
 	if err != nil {
-		return "", err
+		return err
 	}
 
-	return userIDString, nil
+	_, err = (*zap).Users().SetUserEnabled(contextID, userID, "True")
+	return err
 }
 
-func addUsers(cfg *Config, zap *zap.Interface, ctx *Context, credentialString string) error {
+func addUsers(cfg *ContextConfig, zap *zap.Interface, ctx *Context, credentialString string) error {
 	for i := range cfg.credentials {
 		cred := cfg.credentials[i]
 		userID, err := addUser(zap, ctx.ContextID, cred.Username, cred.Password, credentialString)
@@ -336,6 +502,22 @@ func getZapStringResult(resultKey string, result map[string]interface{}) (string
 	return zapResult.(string), err
 }
 
+func getZapStringListResult(resultKey string, result map[string]interface{}) ([]string, error) {
+	zapResult, err := getZapResult(resultKey, result)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]string, 0)
+	list, _ := zapResult.([]interface{})
+	for _, v := range list {
+		if s, ok := v.(string); ok {
+			values = append(values, s)
+		}
+	}
+	return values, nil
+}
+
 func getZapIntResult(resultKey string, result map[string]interface{}) (int, error) {
 	str, err := getZapStringResult(resultKey, result)
 	if err != nil {
@@ -361,6 +543,18 @@ func SpiderAsUser(zap *zap.Interface, targetURL string, contextID string, userID
 	return runSpider(zap, targetURL, userID, contextID, "")
 }
 
+// SetMode sets the ZAP mode.
+// It returns an error when a failure occurs.
+func SetMode(zap *zap.Interface, mode string) error {
+
+	result, err := (*zap).Core().SetMode(mode)
+	if err != nil {
+		return err
+	}
+	_, err = getZapResult("Result", result)
+	return err
+}
+
 // ForceUser enables forced user mode for the specified user.
 // It returns an error when a failure occurs.
 func ForceUser(zap *zap.Interface, contextID string, userID string) error {
@@ -549,7 +743,13 @@ func ApplyXslt(xsltProgram string, outputFileName string, minimumRiskCode int, m
   <xsl:template match="OWASPZAPReport/site/alerts/alertitem[confidence &lt; %d]"/>
 </xsl:stylesheet>`
 
-	xf, err := ioutil.TempFile("", "report-filter-xslt")
+	return runXslt(xsltProgram, fmt.Sprintf(xslt, minimumRiskCode, minimumConfidence), outputFileName)
+}
+
+// runXslt transforms a report file in place with an XSLT stylesheet.
+func runXslt(xsltProgram string, xslt string, outputFileName string) error {
+
+	xf, err := ioutil.TempFile("", "report-xslt")
 	if err != nil {
 		return err
 	}
@@ -559,7 +759,7 @@ func ApplyXslt(xsltProgram string, outputFileName string, minimumRiskCode int, m
 		}
 	}()
 
-	_, err = xf.WriteString(fmt.Sprintf(xslt, minimumRiskCode, minimumConfidence))
+	_, err = xf.WriteString(xslt)
 	if err != nil {
 		if err := xf.Close(); err != nil {
 			log.Println(err)
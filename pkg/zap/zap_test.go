@@ -0,0 +1,57 @@
+package zap
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/codedx/codedx-add-ins/pkg/assert"
+	"github.com/zaproxy/zap-api-go/zap"
+)
+
+// newFakeZapClient returns a ZAP client whose API requests reach a fake ZAP, and a function listing the API views
+// and actions, such as authentication/action/setLoggedInIndicator, that the client requested. The fake ZAP answers
+// with the JSON response for the requested view or action, or with an OK result when there is none.
+func newFakeZapClient(t *testing.T, responses map[string]string) (*zap.Interface, func() []string) {
+
+	var mutex sync.Mutex
+	calls := make([]string, 0)
+
+	// the client sends its API requests to the fake ZAP as a proxy
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call := strings.Trim(strings.TrimPrefix(r.URL.Path, "/JSON/"), "/")
+		mutex.Lock()
+		calls = append(calls, call)
+		mutex.Unlock()
+
+		response, ok := responses[call]
+		if !ok {
+			response = `{"Result": "OK"}`
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if _, err := w.Write([]byte(response)); err != nil {
+			t.Error(err)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	client, err := zap.NewClient(&zap.Config{Proxy: server.URL})
+	assert.NilError(t, err)
+
+	return &client, func() []string {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return append([]string{}, calls...)
+	}
+}
+
+func TestEncodeParametersSuffix(t *testing.T) {
+
+	params := []scriptParameter{
+		{Name: "loginUrl", Value: "http://localhost/login?a=b"},
+		{Name: "method", Value: "POST"},
+	}
+	assert.StringsAreEqual(t, "&loginUrl=http%3A%2F%2Flocalhost%2Flogin%3Fa%3Db&method=POST", encodeParametersSuffix(params))
+}